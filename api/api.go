@@ -1,25 +1,75 @@
 package api
 
 import (
+	"context"
+	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/sunilkumarmohanty/tictactoe/api/v1"
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
 )
 
-// Run starts the server
-func Run() {
+// Run starts the server and blocks until it fails or is stopped by SIGINT or SIGTERM.
+// When stopped it drains the active requests and closes the repository
+func Run(cfg *config.Config) error {
 	router := mux.NewRouter().StrictSlash(false)
-	v1.MakeHandlers(router)
+	router.Handle("/metrics", metrics.Handler()).Methods("GET")
+	gameHandlers, err := v1.MakeHandlers(router, cfg)
+	if err != nil {
+		return err
+	}
 
-	port, err := strconv.Atoi(os.Getenv("PORT"))
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	listener, err := net.Listen("tcp", cfg.ListenAddr)
+	if err == nil {
+		err = serve(newServer(router, gameHandlers), listener, stop, cfg.ShutdownTimeout)
+	}
+	closeErr := gameHandlers.Close()
 	if err != nil {
-		log.Println("Invalid PORT or PORT not set in environment variable")
-		port = 8080
+		return err
+	}
+	return closeErr
+}
+
+// newServer returns the server of the router. The event streams and sockets of the handlers are ended
+// as soon as the server shuts down, as they would otherwise keep it waiting until the timeout
+func newServer(router *mux.Router, gameHandlers *v1.Handlers) *http.Server {
+	server := &http.Server{Handler: router}
+	server.RegisterOnShutdown(gameHandlers.Shutdown)
+	return server
+}
+
+// serve serves on the listener until the server fails or a signal is received on stop.
+// The server then stops accepting connections and waits up to timeout for the active requests to complete,
+// after which the remaining connections are closed, cancelling the context of their requests
+func serve(server *http.Server, listener net.Listener, stop <-chan os.Signal, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-stop:
+		log.Printf("Received %v, shutting down", sig)
 	}
 
-	log.Fatal(http.ListenAndServe(":"+strconv.Itoa(port), router))
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err := server.Shutdown(ctx)
+	if err != nil {
+		server.Close()
+		return fmt.Errorf("requests still active after %v were cut off", timeout)
+	}
+	log.Println("Server stopped")
+	return nil
 }
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/sunilkumarmohanty/tictactoe/api/v1"
+	"github.com/sunilkumarmohanty/tictactoe/config"
+)
+
+func Test_serve(t *testing.T) {
+	tests := []struct {
+		name     string
+		delay    time.Duration // time the request takes
+		timeout  time.Duration
+		wantErr  bool
+		wantBody string
+	}{
+		{
+			name:     "Drained",
+			delay:    50 * time.Millisecond,
+			timeout:  time.Second,
+			wantBody: "done",
+		},
+		{
+			name:    "Cut Off",
+			delay:   time.Second,
+			timeout: 50 * time.Millisecond,
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			started := make(chan struct{})
+			server := &http.Server{Handler: http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				close(started)
+				select {
+				case <-time.After(tt.delay):
+					rw.Write([]byte("done"))
+				case <-r.Context().Done():
+				}
+			})}
+			stop := make(chan os.Signal, 1)
+			served := make(chan error, 1)
+			go func() {
+				served <- serve(server, listener, stop, tt.timeout)
+			}()
+
+			bodies := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + listener.Addr().String())
+				if err != nil {
+					bodies <- ""
+					return
+				}
+				defer resp.Body.Close()
+				body, _ := ioutil.ReadAll(resp.Body)
+				bodies <- string(body)
+			}()
+			<-started
+			stop <- syscall.SIGTERM
+
+			err = <-served
+			if (err != nil) != tt.wantErr {
+				t.Errorf("serve() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if body := <-bodies; body != tt.wantBody {
+				t.Errorf("response body = %v, want %v", body, tt.wantBody)
+			}
+			if _, err := http.Get("http://" + listener.Addr().String()); err == nil {
+				t.Errorf("server still accepting connections after shutdown")
+			}
+		})
+	}
+}
+
+// Test_serveStreams checks that an open event stream and an open socket do not hold up the shutdown
+func Test_serveStreams(t *testing.T) {
+	cfg := config.Default()
+	cfg.DB.SQLConn = "memory://"
+	router := mux.NewRouter()
+	gameHandlers, err := v1.MakeHandlers(router, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	stop := make(chan os.Signal, 1)
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(newServer(router, gameHandlers), listener, stop, time.Second)
+	}()
+
+	resp, err := http.Post("http://"+address+"/api/v1/games", "application/json", strings.NewReader(`{"board": "---------"}`))
+	if err != nil {
+		t.Fatalf("creating game: %v", err)
+	}
+	created := struct {
+		Location string `json:"location"`
+	}{}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+address+created.Location+"/ws", nil)
+	if err != nil {
+		t.Fatalf("dialing socket: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err != nil {
+		t.Fatalf("reading state of the game: %v", err)
+	}
+	stream, err := http.Get("http://" + address + "/api/v1/events")
+	if err != nil {
+		t.Fatalf("following events: %v", err)
+	}
+	defer stream.Body.Close()
+
+	stop <- syscall.SIGTERM
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Errorf("serve() error = %v, want <nil>", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve() still running")
+	}
+	if _, err := ioutil.ReadAll(stream.Body); err != nil {
+		t.Errorf("reading stream error = %v, want the stream to end", err)
+	}
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseGoingAway) {
+		t.Errorf("socket error = %v, want going away", err)
+	}
+	if err := gameHandlers.Close(); err != nil {
+		t.Errorf("Handlers.Close() error = %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+package v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// Codes of the errors reported by the API. They are stable, clients can rely on them rather than on the detail
+const (
+	codeInvalidRequest      = "INVALID_REQUEST"
+	codeInvalidParameter    = "INVALID_PARAMETER"
+	codeInvalidCursor       = "INVALID_CURSOR"
+	codeInvalidStrategy     = "INVALID_STRATEGY"
+	codeInvalidDifficulty   = "INVALID_DIFFICULTY"
+	codeInvalidBoard        = "INVALID_BOARD"
+	codeInvalidMark         = "INVALID_MARK"
+	codeInvalidPosition     = "INVALID_POSITION"
+	codeCellOccupied        = "CELL_OCCUPIED"
+	codeNoMove              = "NO_MOVE"
+	codeNotYourTurn         = "NOT_YOUR_TURN"
+	codeInvalidMode         = "INVALID_MODE"
+	codeNotMultiplayer      = "NOT_MULTIPLAYER"
+	codeGameFull            = "GAME_FULL"
+	codePlayerTokenRequired = "PLAYER_TOKEN_REQUIRED"
+	codeInvalidPlayerToken  = "INVALID_PLAYER_TOKEN"
+	codeUndoNotSupported    = "UNDO_NOT_SUPPORTED"
+	codeInvalidURL          = "INVALID_URL"
+	codeInvalidEvent        = "INVALID_EVENT"
+	codeWebhookNotFound     = "WEBHOOK_NOT_FOUND"
+	codeDeliveryNotFound    = "DELIVERY_NOT_FOUND"
+	codeDeliveryNotFailed   = "DELIVERY_NOT_FAILED"
+	codeStateMismatch       = "STATE_MISMATCH"
+	codeGameOver            = "GAME_OVER"
+	codeGameModified        = "GAME_MODIFIED"
+	codeGameNotFound        = "GAME_NOT_FOUND"
+	codeUndoLimitReached    = "UNDO_LIMIT_REACHED"
+	codeNothingToUndo       = "NOTHING_TO_UNDO"
+	codeTimeout             = "TIMEOUT"
+	codeUnavailable         = "UNAVAILABLE"
+	codeInternal            = "INTERNAL_ERROR"
+)
+
+// Kinds of board violations, telling exactly why a board posted by the client was rejected
+const (
+	violationBoardLength     = "BOARD_LENGTH"     // the board does not have 9 cells
+	violationUnknownMark     = "UNKNOWN_MARK"     // a cell holds something else than X, O or -
+	violationTooManyMoves    = "TOO_MANY_MOVES"   // a new board has more than one move
+	violationNoChange        = "NO_CHANGE"        // the board is the stored board
+	violationCellsChanged    = "CELLS_CHANGED"    // more than one cell differs from the stored board
+	violationWrongMark       = "WRONG_MARK"       // the mark of the computer or of the other player was played
+	violationMarkOverwritten = "MARK_OVERWRITTEN" // an existing mark was replaced
+	violationMarkRemoved     = "MARK_REMOVED"     // an existing mark was cleared
+)
+
+// violationCodes are the error codes sent for the board violations
+var violationCodes = map[string]string{
+	violationBoardLength:     codeInvalidBoard,
+	violationUnknownMark:     codeInvalidMark,
+	violationTooManyMoves:    codeInvalidBoard,
+	violationNoChange:        codeNoMove,
+	violationCellsChanged:    codeStateMismatch,
+	violationWrongMark:       codeInvalidMark,
+	violationMarkOverwritten: codeCellOccupied,
+	violationMarkRemoved:     codeStateMismatch,
+}
+
+// contentTypeProblem is the media type of the error responses, see RFC 7807
+const contentTypeProblem = "application/problem+json"
+
+// apiError is an error sent to the client
+type apiError struct {
+	status    int    // status code of the response
+	code      string // one of the code constants
+	detail    string // human readable explanation
+	field     string // field of the request at fault, if any
+	position  *int   // position of the board at fault, if any
+	positions []int  // positions of the board at fault when there are several
+	violation string // kind of the board violation, if any
+}
+
+func newAPIError(status int, code string, detail string) *apiError {
+	return &apiError{status: status, code: code, detail: detail}
+}
+
+func (e *apiError) Error() string {
+	return e.detail
+}
+
+// withField names the field of the request at fault
+func (e *apiError) withField(field string) *apiError {
+	e.field = field
+	return e
+}
+
+// atPosition points at the position of the board at fault
+func (e *apiError) atPosition(position int) *apiError {
+	e.position = &position
+	return e
+}
+
+// boardViolation describes why a board posted by the client cannot be accepted
+type boardViolation struct {
+	kind      string // one of the violation constants
+	detail    string // human readable explanation
+	position  *int   // position of the board at fault, if any
+	positions []int  // positions of the board at fault when there are several
+}
+
+func (v *boardViolation) Error() string {
+	return v.detail
+}
+
+// apiError returns the error sent for the violation
+func (v *boardViolation) apiError() *apiError {
+	err := newAPIError(http.StatusBadRequest, violationCodes[v.kind], v.detail).withField("board")
+	err.position = v.position
+	err.positions = v.positions
+	err.violation = v.kind
+	return err
+}
+
+// problem is the body of the error responses, following RFC 7807 with the error code and the request ID as extensions
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail,omitempty"`
+	Field     string `json:"field,omitempty"`
+	Position  *int   `json:"position,omitempty"`
+	Positions []int  `json:"positions,omitempty"`
+	Violation string `json:"violation,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// sendError sends err as problem+json. The request ID is taken from the response headers set by instrument
+func sendError(rw http.ResponseWriter, err *apiError) {
+	rw.Header().Set("Content-Type", contentTypeProblem)
+	rw.WriteHeader(err.status)
+	json.NewEncoder(rw).Encode(newProblem(err, rw.Header().Get(headerRequestID)))
+}
+
+// newProblem returns the problem describing err
+func newProblem(err *apiError, requestID string) problem {
+	return problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(err.status),
+		Status:    err.status,
+		Code:      err.code,
+		Detail:    err.detail,
+		Field:     err.field,
+		Position:  err.position,
+		Positions: err.positions,
+		Violation: err.violation,
+		RequestID: requestID,
+	}
+}
+
+// repositoryError returns the error sent for an error returned by the repository.
+// A call which ran out of time is reported as a gateway timeout, a call abandoned because the request was cancelled as unavailable
+func repositoryError(err error) *apiError {
+	switch err {
+	case context.DeadlineExceeded:
+		return newAPIError(http.StatusGatewayTimeout, codeTimeout, "database call timed out")
+	case context.Canceled:
+		return newAPIError(http.StatusServiceUnavailable, codeUnavailable, "request cancelled")
+	}
+	return newAPIError(http.StatusInternalServerError, codeInternal, msgInternalServerError)
+}
+
+// gameNotFound is the error sent when the game of the request does not exist
+func gameNotFound() *apiError {
+	return newAPIError(http.StatusNotFound, codeGameNotFound, "game not found")
+}
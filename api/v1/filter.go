@@ -0,0 +1,89 @@
+package v1
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parseGameFilter reads the filter for listing games from the query of the request.
+// Returns the error if the query is invalid
+func parseGameFilter(r *http.Request) (*repository.GameFilter, *apiError) {
+	query := r.URL.Query()
+	filter := &repository.GameFilter{
+		Limit:  defaultPageSize,
+		Cursor: query.Get("cursor"),
+		Sort:   repository.SortCreatedDesc,
+	}
+	if limit := query.Get("limit"); len(limit) != 0 {
+		var err error
+		filter.Limit, err = strconv.Atoi(limit)
+		if err != nil || filter.Limit < 1 || filter.Limit > maxPageSize {
+			return nil, invalidParameter("limit")
+		}
+	}
+	if statuses := query.Get("status"); len(statuses) != 0 {
+		for _, status := range strings.Split(statuses, ",") {
+			switch status {
+			case gameStatusRunning, gameStatusXWon, gameStatusOWon, gameStatusDraw:
+				filter.Statuses = append(filter.Statuses, status)
+			default:
+				return nil, invalidParameter("status")
+			}
+		}
+	}
+	if mark := query.Get("computer_mark"); len(mark) != 0 {
+		if mark != xMark && mark != oMark {
+			return nil, invalidParameter("computer_mark")
+		}
+		filter.ComputerMark = mark
+	}
+	timeParams := []struct {
+		name  string
+		value *time.Time
+	}{
+		{"created_after", &filter.CreatedAfter},
+		{"created_before", &filter.CreatedBefore},
+		{"updated_after", &filter.UpdatedAfter},
+		{"updated_before", &filter.UpdatedBefore},
+		{"finished_after", &filter.FinishedAfter},
+		{"finished_before", &filter.FinishedBefore},
+	}
+	for _, param := range timeParams {
+		var ok bool
+		if *param.value, ok = parseTime(query.Get(param.name)); !ok {
+			return nil, invalidParameter(param.name)
+		}
+	}
+	if sort := query.Get("sort"); len(sort) != 0 {
+		switch sort {
+		case repository.SortCreatedAsc, repository.SortCreatedDesc, repository.SortUpdatedAsc, repository.SortUpdatedDesc:
+		default:
+			return nil, invalidParameter("sort")
+		}
+		filter.Sort = sort
+	}
+	return filter, nil
+}
+
+// invalidParameter is the error sent for an invalid query parameter
+func invalidParameter(name string) *apiError {
+	return newAPIError(http.StatusBadRequest, codeInvalidParameter, "invalid "+name).withField(name)
+}
+
+// parseTime parses an optional RFC 3339 time. A blank value results in the zero time
+func parseTime(value string) (time.Time, bool) {
+	if len(value) == 0 {
+		return time.Time{}, true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, err == nil
+}
@@ -1,16 +1,27 @@
 package v1
 
 import (
+	"fmt"
+	"hash/fnv"
 	"math/rand"
+	"net/http"
 	"strings"
 	"time"
 
 	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
 )
 
 // Game represent the tic tac toe game
 type Game struct {
-	Board string `json:"board,omitempty"`
+	Mode       string `json:"mode,omitempty"`
+	Mark       string `json:"mark,omitempty"` // mark of the player creating a game between humans
+	Board      string `json:"board,omitempty"`
+	Strategy   string `json:"strategy,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Seed       *int64 `json:"seed,omitempty"`
 }
 
 const (
@@ -20,136 +31,255 @@ const (
 	gameStatusDraw    = "DRAW"
 )
 
-// validateNewGame validates the new board and returns computer mark and if the board is OK
-func (g *Game) validateNewGame() (string, bool) {
+const (
+	strategyRandom    = "random"    // picks any blank position
+	strategyHeuristic = "heuristic" // wins or blocks when it can, else prefers center and corners
+	strategyMinimax   = "minimax"   // perfect play, never loses
+)
+
+const (
+	playerHuman    = "human"
+	playerComputer = "computer"
+)
+
+const (
+	difficultyEasy    = "easy"
+	difficultyMedium  = "medium"
+	difficultyHard    = "hard"
+	difficultyPerfect = "perfect"
+)
+
+// difficultyRates is the probability of the computer playing the strategy move instead of a random one
+var difficultyRates = map[string]float64{
+	difficultyEasy:    0.25,
+	difficultyMedium:  0.5,
+	difficultyHard:    0.8,
+	difficultyPerfect: 1,
+}
+
+// validateNewGame validates the new board and returns the computer mark, or the violation if the board is not a valid new board
+func (g *Game) validateNewGame() (string, *boardViolation) {
 	//check for the length of the board
 	if len(g.Board) != 9 {
-		logger.Error("invalid board", zap.String("board", g.Board))
-
-		return "", false
+		return "", &boardViolation{
+			kind:   violationBoardLength,
+			detail: fmt.Sprintf("board has %d cells, 9 required", len(g.Board)),
+		}
 	}
 	xMoves, oMoves := 0, 0
 	moves := strings.Split(g.Board, "")
-	// The number of moves should be less than equal to 1
 	// Only X, O and - allowed in the board
-	for _, move := range moves {
+	for indx, move := range moves {
 		switch move {
 		case xMark:
-			if xMoves+oMoves == 1 {
-				logger.Error("more than one move made", zap.String("board", g.Board))
-				return "", false
-			}
 			xMoves++
 		case oMark:
-			if xMoves+oMoves == 1 {
-				logger.Error("more than one move made", zap.String("board", g.Board))
-				return "", false
-			}
 			oMoves++
-		case "-":
+		case fMark:
 		default:
-			logger.Error("invalid move", zap.String("move", move))
-			return "", false
+			return "", &boardViolation{
+				kind:     violationUnknownMark,
+				detail:   fmt.Sprintf("unknown mark %q at position %d", move, indx),
+				position: &indx,
+			}
+		}
+	}
+	// The number of moves should be less than equal to 1
+	if xMoves+oMoves > 1 {
+		return "", &boardViolation{
+			kind:   violationTooManyMoves,
+			detail: fmt.Sprintf("board has %d moves, at most 1 allowed", xMoves+oMoves),
 		}
-
 	}
 	if xMoves == 1 {
-		return oMark, true
+		return oMark, nil
 	}
-	return xMark, true
+	return xMark, nil
 }
 
-func (g *Game) validateBoard() bool {
+// validateStrategy checks the requested computer strategy.
+// When not set it defaults to minimax if a difficulty is requested, else to defaultStrategy or random if that is empty
+func (g *Game) validateStrategy(defaultStrategy string) bool {
+	if g.Strategy == "" {
+		g.Strategy = defaultStrategy
+		if g.Strategy == "" {
+			g.Strategy = strategyRandom
+		}
+		if g.Difficulty != "" {
+			g.Strategy = strategyMinimax
+		}
+	}
+	if _, ok := getStrategy(g.Strategy); !ok {
+		logging.Logger().Error("invalid strategy", zap.String("strategy", g.Strategy))
+		return false
+	}
+	return true
+}
+
+// validateDifficulty checks the requested difficulty. When not set it defaults to defaultDifficulty or perfect if that is empty
+func (g *Game) validateDifficulty(defaultDifficulty string) bool {
+	if g.Difficulty == "" {
+		g.Difficulty = defaultDifficulty
+	}
+	if g.Difficulty == "" {
+		g.Difficulty = difficultyPerfect
+	}
+	if _, ok := difficultyRates[g.Difficulty]; !ok {
+		logging.Logger().Error("invalid difficulty", zap.String("difficulty", g.Difficulty))
+		return false
+	}
+	return true
+}
+
+// newSeed returns the seed requested for the game or a random seed when not set
+func (g *Game) newSeed() int64 {
+	if g.Seed != nil {
+		return *g.Seed
+	}
+	return time.Now().UnixNano()
+}
+
+// validateBoard checks the length and the marks of the board. Returns nil if the board is OK
+func (g *Game) validateBoard() *apiError {
 	// check for length of board
 	if len(g.Board) != 9 {
-		logger.Error("invalid board", zap.String("board", g.Board))
-		return false
+		return newAPIError(http.StatusBadRequest, codeInvalidBoard, "invalid board").withField("board")
 	}
 	//check for invalid mark in the board
 	moves := strings.Split(g.Board, "")
-	for _, move := range moves {
+	for indx, move := range moves {
 		switch move {
 		case xMark, oMark, fMark:
 		default:
-			logger.Error("invalid board", zap.String("move", g.Board))
-			return false
+			return newAPIError(http.StatusBadRequest, codeInvalidMark, "invalid board").withField("board").atPosition(indx)
 		}
 	}
-	return true
+	return nil
 }
 
-// validatePlay validates if the player made exactly one move and if the move is valid
-// Returns
-//	0 if no move made
-//  1 if 1 correct move made
-//  -1 invalid move (or state of board) detected
-func (g *Game) validatePlay(prevState *Game, curPlayerMark string) int {
+// validatePlay validates if the opponent made exactly one move with its mark on a blank position of the previous board.
+// Returns the position of the move, or the violation if the board does not follow from the previous board
+func (g *Game) validatePlay(prevState *Game, curPlayerMark string) (int, *boardViolation) {
 	curMoves := strings.Split(g.Board, "")
 	prevMoves := strings.Split(prevState.Board, "")
 	opponentMark := findOpponentMark(curPlayerMark)
-	diffs := 0
+	var changed []int
 	for indx, move := range curMoves {
 		if move != prevMoves[indx] {
-			// check if opponent has made a valid move with respect to mark and number of moves
-			if move != opponentMark || diffs == 1 {
-				//the play does not complement to its previous state if there are more than 1 diff
-				return -1
-			}
-			diffs++
+			changed = append(changed, indx)
+		}
+	}
+	if len(changed) == 0 {
+		return -1, &boardViolation{kind: violationNoChange, detail: "no move made"}
+	}
+	//the play does not complement to its previous state if there are more than 1 diff
+	if len(changed) > 1 {
+		return -1, &boardViolation{
+			kind:      violationCellsChanged,
+			detail:    fmt.Sprintf("%d cells changed, only 1 move allowed", len(changed)),
+			positions: changed,
 		}
 	}
-	return diffs
+	position := changed[0]
+	switch {
+	case prevMoves[position] != fMark && curMoves[position] == fMark:
+		return -1, &boardViolation{
+			kind:     violationMarkRemoved,
+			detail:   fmt.Sprintf("%s removed at position %d", prevMoves[position], position),
+			position: &position,
+		}
+	case prevMoves[position] != fMark:
+		return -1, &boardViolation{
+			kind:     violationMarkOverwritten,
+			detail:   fmt.Sprintf("%s overwritten by %s at position %d", prevMoves[position], curMoves[position], position),
+			position: &position,
+		}
+	case curMoves[position] != opponentMark:
+		return -1, &boardViolation{
+			kind:     violationWrongMark,
+			detail:   fmt.Sprintf("%s played at position %d, the opponent plays %s", curMoves[position], position, opponentMark),
+			position: &position,
+		}
+	}
+	return position, nil
 }
 
-// play checks for blank positions and randomly selects a blank space and makes it move
-func (g *Game) play(mark string) {
+// play makes the computer move using the strategy registered by strategyName.
+// Depending on the difficulty some of the moves are replaced by random ones.
+// The moves are reproducible for the same seed and board.
+// Returns the position played or -1 if the board is full
+func (g *Game) play(mark, strategyName, difficulty string, seed int64) int {
 	moves := strings.Split(g.Board, "")
-	validPositions := findBlankPositions(moves)
 	// make move only when valid position found
-	if len(validPositions) > 0 {
-		// randomly select a blank valid position
-		rand := rand.New(rand.NewSource(time.Now().UnixNano()))
-		randomMovePosition := validPositions[rand.Intn(len(validPositions))]
-		moves[randomMovePosition] = mark
-		g.Board = strings.Join(moves, "")
+	if len(findBlankPositions(moves)) == 0 {
+		return -1
+	}
+	rand := newRand(seed, g.Board)
+	strategy, ok := getStrategy(strategyName)
+	if !ok || rand.Float64() >= difficultyRates[difficulty] {
+		strategy = randomStrategy{}
+	}
+	position := strategy.Move(g.Board, mark, rand)
+	moves[position] = mark
+	g.Board = strings.Join(moves, "")
+	return position
+}
+
+// newMove returns the history record for the mark played at position on the board
+func (g *Game) newMove(position int, player string) repository.Move {
+	moves := strings.Split(g.Board, "")
+	return repository.Move{
+		Ply:      len(moves) - len(findBlankPositions(moves)),
+		Mark:     moves[position],
+		Position: position,
+		Player:   player,
 	}
 }
 
+// newRand returns a random number generator derived from the game seed and the current board
+func newRand(seed int64, board string) *rand.Rand {
+	h := fnv.New64a()
+	h.Write([]byte(board))
+	return rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+}
+
 func (g *Game) getStatus() string {
 	moves := strings.Split(g.Board, "")
-	var winner string
+	winner := findWinner(moves)
+	if winner == xMark {
+		return gameStatusXWon
+	}
+	if winner == oMark {
+		return gameStatusOWon
+	}
+	//check if game ended
+	if len(findBlankPositions(moves)) == 0 {
+		return gameStatusDraw
+	}
+	return gameStatusRunning
+}
 
+// findWinner returns the mark which has completed a line or blank if there is no winner yet
+func findWinner(moves []string) string {
 	for i := 0; i < 3; i++ {
 		//horizontal check
 		if moves[i*3] == moves[i*3+1] && moves[i*3] == moves[i*3+2] && moves[i*3] != fMark {
-			winner = moves[i*3]
-			break
+			return moves[i*3]
 		}
 		//vertical check
 		if moves[i] == moves[i+3] && moves[i+6] == moves[i] && moves[i] != fMark {
-			winner = moves[i]
-			break
+			return moves[i]
 		}
 	}
 	//diagonal check
 	if moves[0] == moves[4] && moves[0] == moves[8] && moves[0] != fMark {
-		winner = moves[0]
+		return moves[0]
 	}
 	//diagonal check
 	if moves[2] == moves[4] && moves[2] == moves[6] && moves[2] != fMark {
-		winner = moves[2]
-	}
-	if winner == xMark {
-		return gameStatusXWon
-	}
-	if winner == oMark {
-		return gameStatusOWon
+		return moves[2]
 	}
-	//check if game ended
-	if len(findBlankPositions(moves)) == 0 {
-		return gameStatusDraw
-	}
-	return gameStatusRunning
+	return ""
 }
 
 func findBlankPositions(moves []string) []int {
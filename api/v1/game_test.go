@@ -1,6 +1,9 @@
 package v1
 
-import "testing"
+import (
+	"reflect"
+	"testing"
+)
 
 func TestGame_getStatus(t *testing.T) {
 	type fields struct {
@@ -149,3 +152,217 @@ func TestGame_getStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestGame_validateStrategy(t *testing.T) {
+	tests := []struct {
+		name              string
+		game              Game
+		defaultStrategy   string
+		defaultDifficulty string
+		want              bool
+		wantStrategy      string
+		wantDifficulty    string
+	}{
+		{
+			name:           "Defaults",
+			game:           Game{},
+			want:           true,
+			wantStrategy:   strategyRandom,
+			wantDifficulty: difficultyPerfect,
+		},
+		{
+			name:              "Configured Defaults",
+			game:              Game{},
+			defaultStrategy:   strategyHeuristic,
+			defaultDifficulty: difficultyMedium,
+			want:              true,
+			wantStrategy:      strategyHeuristic,
+			wantDifficulty:    difficultyMedium,
+		},
+		{
+			name:           "Difficulty Defaults To Minimax",
+			game:           Game{Difficulty: difficultyEasy},
+			want:           true,
+			wantStrategy:   strategyMinimax,
+			wantDifficulty: difficultyEasy,
+		},
+		{
+			name:           "Random Strategy With Difficulty",
+			game:           Game{Strategy: strategyRandom, Difficulty: difficultyHard},
+			want:           true,
+			wantStrategy:   strategyRandom,
+			wantDifficulty: difficultyHard,
+		},
+		{
+			name: "Invalid Difficulty",
+			game: Game{Difficulty: "impossible"},
+			want: false,
+		},
+		{
+			name: "Invalid Strategy",
+			game: Game{Strategy: "cheat"},
+			want: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := tt.game
+			got := g.validateStrategy(tt.defaultStrategy) && g.validateDifficulty(tt.defaultDifficulty)
+			if got != tt.want {
+				t.Fatalf("Game.validateStrategy() && Game.validateDifficulty() = %v, want %v", got, tt.want)
+			}
+			if got && (g.Strategy != tt.wantStrategy || g.Difficulty != tt.wantDifficulty) {
+				t.Errorf("got strategy %v difficulty %v, want strategy %v difficulty %v", g.Strategy, g.Difficulty, tt.wantStrategy, tt.wantDifficulty)
+			}
+		})
+	}
+}
+
+func TestGame_play_Reproducible(t *testing.T) {
+	for _, difficulty := range []string{difficultyEasy, difficultyMedium, difficultyHard} {
+		for seed := int64(0); seed < 20; seed++ {
+			first := &Game{Board: "X--------"}
+			second := &Game{Board: "X--------"}
+			first.play(oMark, strategyMinimax, difficulty, seed)
+			second.play(oMark, strategyMinimax, difficulty, seed)
+			if first.Board != second.Board {
+				t.Errorf("moves differ for seed %v and difficulty %v : got %v and %v", seed, difficulty, first.Board, second.Board)
+			}
+		}
+	}
+}
+
+func TestGame_validateNewGame(t *testing.T) {
+	tests := []struct {
+		name             string
+		board            string
+		wantComputerMark string
+		wantViolation    string
+		wantPosition     int
+	}{
+		{
+			name:             "Blank Board",
+			board:            "---------",
+			wantComputerMark: xMark,
+		},
+		{
+			name:             "Opening Move",
+			board:            "----X----",
+			wantComputerMark: oMark,
+		},
+		{
+			name:          "Board Length",
+			board:         "----X---",
+			wantViolation: violationBoardLength,
+		},
+		{
+			name:          "Unknown Mark",
+			board:         "---x-----",
+			wantViolation: violationUnknownMark,
+			wantPosition:  3,
+		},
+		{
+			name:          "Too Many Moves",
+			board:         "X---O----",
+			wantViolation: violationTooManyMoves,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Game{Board: tt.board}
+			got, violation := g.validateNewGame()
+			if got != tt.wantComputerMark {
+				t.Errorf("Game.validateNewGame() = %v, want %v", got, tt.wantComputerMark)
+			}
+			checkViolation(t, violation, tt.wantViolation, tt.wantPosition)
+		})
+	}
+}
+
+func TestGame_validatePlay(t *testing.T) {
+	tests := []struct {
+		name          string
+		prevBoard     string
+		board         string
+		want          int
+		wantViolation string
+		wantPosition  int
+		wantPositions []int
+	}{
+		{
+			name:      "Valid Move",
+			prevBoard: "O---X----",
+			board:     "O---X---X",
+			want:      8,
+		},
+		{
+			name:          "No Change",
+			prevBoard:     "O---X----",
+			board:         "O---X----",
+			want:          -1,
+			wantViolation: violationNoChange,
+		},
+		{
+			name:          "Two Cells Changed",
+			prevBoard:     "O---X----",
+			board:         "O-X-X---X",
+			want:          -1,
+			wantViolation: violationCellsChanged,
+			wantPositions: []int{2, 8},
+		},
+		{
+			name:          "Wrong Mark",
+			prevBoard:     "O---X----",
+			board:         "O---X-O--",
+			want:          -1,
+			wantViolation: violationWrongMark,
+			wantPosition:  6,
+		},
+		{
+			name:          "Mark Overwritten",
+			prevBoard:     "O---X----",
+			board:         "X---X----",
+			want:          -1,
+			wantViolation: violationMarkOverwritten,
+			wantPosition:  0,
+		},
+		{
+			name:          "Mark Removed",
+			prevBoard:     "O---X----",
+			board:         "O--------",
+			want:          -1,
+			wantViolation: violationMarkRemoved,
+			wantPosition:  4,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := &Game{Board: tt.board}
+			got, violation := g.validatePlay(&Game{Board: tt.prevBoard}, oMark)
+			if got != tt.want {
+				t.Errorf("Game.validatePlay() = %v, want %v", got, tt.want)
+			}
+			checkViolation(t, violation, tt.wantViolation, tt.wantPosition)
+			if violation != nil && !reflect.DeepEqual(violation.positions, tt.wantPositions) {
+				t.Errorf("Game.validatePlay() positions = %v, want %v", violation.positions, tt.wantPositions)
+			}
+		})
+	}
+}
+
+// checkViolation checks the kind of the violation and the position at fault, if the violation has one
+func checkViolation(t *testing.T, violation *boardViolation, wantKind string, wantPosition int) {
+	t.Helper()
+	if len(wantKind) == 0 {
+		if violation != nil {
+			t.Errorf("violation = %v %v, want none", violation.kind, violation)
+		}
+		return
+	}
+	if violation == nil || violation.kind != wantKind {
+		t.Fatalf("violation = %+v, want %v", violation, wantKind)
+	}
+	if violation.position != nil && *violation.position != wantPosition {
+		t.Errorf("violation position = %v, want %v", *violation.position, wantPosition)
+	}
+}
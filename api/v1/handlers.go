@@ -1,16 +1,23 @@
 package v1
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"net/url"
-	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/gorilla/mux"
 	"go.uber.org/zap"
 
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
 	"github.com/sunilkumarmohanty/tictactoe/repository"
+	"github.com/sunilkumarmohanty/tictactoe/webhooks"
 )
 
 const (
@@ -26,216 +33,491 @@ const (
 
 // Handlers represent the game handlers
 type Handlers struct {
-	repo        IRepository
-	hostAddress string
+	repo              IRepository
+	hub               *events.Hub // changes of the games are published to it, nothing is published if nil
+	dispatcher        *webhooks.Dispatcher
+	hostAddress       string
+	undoLimit         int
+	defaultStrategy   string // strategy of the games created without one, random if empty
+	defaultDifficulty string // difficulty of the games created without one, perfect if empty
+
+	stopping chan struct{}  // closed once the server shuts down, ending the streams and sockets. Never closed if nil
+	stopOnce sync.Once      // stopping is closed once
+	sockets  sync.WaitGroup // sockets still open, which the server does not wait for on shutdown
 }
 
-// New initialises the handlers struct
-func New() *Handlers {
-	logger.Info("Creating handlers")
-	sqlConn := os.Getenv("SQL_CONN")
-	if len(sqlConn) == 0 {
-		logger.Fatal("SQL_CONN not set")
+// New initialises the handlers struct from the configuration and connects to the repository
+func New(cfg *config.Config) (*Handlers, error) {
+	logging.Logger().Info("Creating handlers")
+	if _, ok := getStrategy(cfg.Game.DefaultStrategy); !ok {
+		return nil, fmt.Errorf("invalid default strategy %s", cfg.Game.DefaultStrategy)
 	}
-
-	hostAddress := os.Getenv("HOST_ADDR")
-	_, err := url.Parse(hostAddress)
-	if err != nil {
-		logger.Fatal("invalid HOST_ADDR in environment variable")
+	if _, ok := difficultyRates[cfg.Game.DefaultDifficulty]; !ok {
+		return nil, fmt.Errorf("invalid default difficulty %s", cfg.Game.DefaultDifficulty)
 	}
-	repo, err := repository.New(sqlConn)
+	repo, err := newRepository(&cfg.DB)
 	if err != nil {
-		logger.Fatal("Unable to create repository")
+		return nil, fmt.Errorf("unable to create repository: %v", err)
 	}
 
+	hub := events.NewHub()
 	return &Handlers{
-		repo:        repo,
-		hostAddress: hostAddress,
+		repo:              repo,
+		hub:               hub,
+		dispatcher:        webhooks.NewDispatcher(repo, hub, &cfg.Webhooks),
+		hostAddress:       cfg.HostAddress,
+		undoLimit:         cfg.Game.UndoLimit,
+		defaultStrategy:   cfg.Game.DefaultStrategy,
+		defaultDifficulty: cfg.Game.DefaultDifficulty,
+		stopping:          make(chan struct{}),
+	}, nil
+}
+
+// Shutdown ends the event streams and asks the clients of the sockets to reconnect, so that they do not hold up
+// the shutdown of the server. The hub stays open, so that the changes made by the requests being drained reach the webhooks
+func (h *Handlers) Shutdown() {
+	h.stopOnce.Do(func() {
+		if h.stopping != nil {
+			close(h.stopping)
+		}
+	})
+}
+
+// Close ends the streams and sockets, stops the deliveries to the webhooks, ends the subscriptions to the changes
+// of the games and closes the repository of the handlers once the sockets are closed
+func (h *Handlers) Close() error {
+	h.Shutdown()
+	h.sockets.Wait()
+	h.dispatcher.Close()
+	h.hub.Close()
+	return h.repo.Close()
+}
+
+// memoryConn is the SQL_CONN which selects the in-memory repository instead of postgres
+const memoryConn = "memory://"
+
+// newRepository creates the repository for the database configuration
+func newRepository(cfg *config.DB) (IRepository, error) {
+	if cfg.SQLConn == memoryConn {
+		return repository.NewMemory(), nil
 	}
+	return repository.New(cfg)
 }
 
-// GetAllGamesHandler returns all the games stored in the database
+// GetAllGamesHandler returns a page of the games stored in the database matching the filters of the request
 func (h *Handlers) GetAllGamesHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	rw.Header().Set("Content-Type", "application/json")
-	games, err := h.repo.GetGames()
+	filter, apiErr := parseGameFilter(r)
+	if apiErr != nil {
+		logger.Error("invalid games filter", zap.Error(apiErr), zap.String("query", r.URL.RawQuery))
+		sendError(rw, apiErr)
+		return
+	}
+	page, err := h.repo.GetGames(r.Context(), filter)
+	if err == repository.ErrInvalidCursor {
+		logger.Error("invalid cursor", zap.String("cursor", filter.Cursor))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidCursor, "invalid cursor").withField("cursor"))
+		return
+	}
 	if err != nil {
 		logger.Error("unable to get games", zap.Error(err))
-		rw.WriteHeader(http.StatusInternalServerError)
+		sendError(rw, repositoryError(err))
 		return
 	}
-	json.NewEncoder(rw).Encode(games)
+	json.NewEncoder(rw).Encode(page)
 }
 
 // GetGameHandler returns an instance of a single game
 func (h *Handlers) GetGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	rw.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
-	game, err := h.repo.GetGame(params["game_id"])
+	game, err := h.repo.GetGame(r.Context(), params["game_id"])
 	if err != nil {
 		logger.Error("unable to get game", zap.Error(err))
-		rw.WriteHeader(http.StatusInternalServerError)
+		sendError(rw, repositoryError(err))
 		return
 	}
 	// game not found
 	if game == nil {
 		logger.Error("game not found", zap.String("gameid", params["game_id"]))
-		rw.WriteHeader(http.StatusNotFound)
+		sendError(rw, gameNotFound())
 		return
 	}
+	rw.Header().Set("ETag", etag(game.Version))
 	json.NewEncoder(rw).Encode(game)
 }
 
 // CreateGameHandler creates a new game
 func (h *Handlers) CreateGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	rw.Header().Set("Content-Type", "application/json")
 	newGame := &Game{}
 	err := json.NewDecoder(r.Body).Decode(newGame)
 	if err != nil {
 		logger.Error("invalid body while creating new game", zap.Error(err))
 
-		sendJSONError(rw, http.StatusBadRequest, "invalid request body")
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid request body").withField("body"))
+		return
+	}
+	switch newGame.Mode {
+	case "", gameModeComputer:
+	case gameModeHuman:
+		h.createHumanGame(rw, r, newGame)
+		return
+	default:
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidMode, "mode must be computer or human").withField("mode"))
+		return
+	}
+	if !newGame.validateStrategy(h.defaultStrategy) {
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidStrategy, "invalid strategy").withField("strategy"))
+		return
+	}
+	if !newGame.validateDifficulty(h.defaultDifficulty) {
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidDifficulty, "invalid difficulty").withField("difficulty"))
 		return
 	}
 	// Check if the new board is valid. If valid, then make a move and save the state
-	if computerMark, ok := newGame.validateNewGame(); ok {
-		// computer makes the move
-		newGame.play(computerMark)
-		// save the game
-		gameID, err := h.repo.NewGame(computerMark, newGame.Board)
-		if err != nil {
-			logger.Error("game creation failed", zap.Error(err))
-			rw.WriteHeader(http.StatusInternalServerError)
-			return
-		}
-		resp := newGameResponse{
-			Location: fmt.Sprintf("%s/%s/%s", h.hostAddress, "api/v1/games", gameID),
-		}
-		rw.WriteHeader(http.StatusCreated)
-		json.NewEncoder(rw).Encode(resp)
+	computerMark, violation := newGame.validateNewGame()
+	if violation != nil {
+		logger.Error("invalid new board", zap.String("board", newGame.Board), zap.String("violation", violation.kind), zap.Error(violation))
+		sendError(rw, violation.apiError())
+		return
+	}
+	var moves []repository.Move
+	// record the opening move of the opponent if any
+	if position := strings.IndexAny(newGame.Board, xMark+oMark); position != -1 {
+		moves = append(moves, newGame.newMove(position, playerHuman))
+	}
+	seed := newGame.newSeed()
+	// computer makes the move
+	position := newGame.play(computerMark, newGame.Strategy, newGame.Difficulty, seed)
+	moves = append(moves, newGame.newMove(position, playerComputer))
+	// save the game
+	dbGame := &repository.Game{
+		Mode:         gameModeComputer,
+		Board:        newGame.Board,
+		ComputerMark: computerMark,
+		Strategy:     newGame.Strategy,
+		Difficulty:   newGame.Difficulty,
+		Seed:         seed,
+	}
+	gameID, err := h.repo.NewGame(r.Context(), dbGame, moves, nil)
+	if err != nil {
+		logger.Error("game creation failed", zap.Error(err))
+		sendError(rw, repositoryError(err))
 		return
 	}
-	sendJSONError(rw, http.StatusBadRequest, "invalid new board")
+	logger.Info("game created", zap.String("game_id", gameID))
+	metrics.GamesCreated.WithLabelValues(computerMark).Inc()
+	h.hub.Publish(events.GameCreated, gameID, dbGame)
+	resp := newGameResponse{
+		Location: h.gameLocation(gameID),
+	}
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(resp)
 }
 
-// UpdateGameHandler handles a move made by opponent and if required makes the computer move. It also saves the result in db
+// UpdateGameHandler handles a move made by opponent and if required makes the computer move. It also saves the result in db.
+// The game is read, played and saved in a single repository operation, so concurrent moves on a game are serialized.
+// In games between humans the move is made by the player holding the token of the request and the computer never moves
 func (h *Handlers) UpdateGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	rw.Header().Set("Content-Type", "application/json")
 	gameID := mux.Vars(r)["game_id"]
 	curGame := &Game{}
 	err := json.NewDecoder(r.Body).Decode(curGame)
 	if err != nil {
 		logger.Error("invalid body while updating game", zap.Error(err))
-		sendJSONError(rw, http.StatusBadRequest, "invalid request body")
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid request body").withField("body"))
 		return
 	}
 	//Validate board
-	if !curGame.validateBoard() {
-		logger.Error("invalid board", zap.Any("game", curGame))
-		sendJSONError(rw, http.StatusBadRequest, "invalid board")
+	if apiErr := curGame.validateBoard(); apiErr != nil {
+		logger.Error("invalid board", zap.Any("game", curGame), zap.Error(apiErr))
+		sendError(rw, apiErr)
+		return
+	}
+	c, err := h.requestClient(r, gameID)
+	if err != nil {
+		h.sendPlayedGame(rw, r, gameID, nil, err)
+		return
+	}
+	game, err := h.repo.PlayMove(r.Context(), gameID, func(storedState *repository.Game) ([]repository.Move, error) {
+		mark, apiErr := c.moveMark(storedState)
+		if apiErr != nil {
+			logger.Error("move not allowed", zap.String("gameid", gameID), zap.Error(apiErr))
+			return nil, apiErr
+		}
+		//Check if play made by opponent is valid. Compare the game with the previous state
+		position, violation := curGame.validatePlay(&Game{
+			Board: storedState.Board,
+		}, findOpponentMark(mark))
+		if violation != nil {
+			logger.Error("invalid play", zap.String("gameid", gameID), zap.String("violation", violation.kind), zap.Error(violation))
+			return nil, violation.apiError()
+		}
+		return playMove(storedState, curGame, position), nil
+	})
+	h.sendPlayedGame(rw, r, gameID, game, err)
+}
+
+// MakeMoveHandler applies a single move made by opponent at the requested position and if required makes the computer move. It also saves the result in db
+func (h *Handlers) MakeMoveHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	gameID := mux.Vars(r)["game_id"]
+	move := &moveRequest{}
+	err := json.NewDecoder(r.Body).Decode(move)
+	if err != nil {
+		logger.Error("invalid body while making move", zap.Error(err))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid request body").withField("body"))
+		return
+	}
+	position, apiErr := move.position()
+	if apiErr != nil {
+		logger.Error("invalid move", zap.String("gameid", gameID), zap.Error(apiErr))
+		sendError(rw, apiErr)
+		return
+	}
+	c, err := h.requestClient(r, gameID)
+	if err != nil {
+		h.sendPlayedGame(rw, r, gameID, nil, err)
+		return
+	}
+	game, err := h.makeMove(r.Context(), gameID, position, c)
+	h.sendPlayedGame(rw, r, gameID, game, err)
+}
+
+// makeMove plays the move of the client at position in a single repository operation and makes the computer move if required
+func (h *Handlers) makeMove(ctx context.Context, gameID string, position int, c *client) (*repository.Game, error) {
+	logger := logging.FromContext(ctx)
+	return h.repo.PlayMove(ctx, gameID, func(storedState *repository.Game) ([]repository.Move, error) {
+		mark, apiErr := c.moveMark(storedState)
+		if apiErr != nil {
+			logger.Error("move not allowed", zap.String("gameid", gameID), zap.Error(apiErr))
+			return nil, apiErr
+		}
+		moves := strings.Split(storedState.Board, "")
+		if moves[position] != fMark {
+			logger.Error("cell already occupied", zap.String("gameid", gameID), zap.Int("position", position))
+			return nil, newAPIError(http.StatusBadRequest, codeCellOccupied, "cell already occupied").atPosition(position)
+		}
+		moves[position] = mark
+		return playMove(storedState, &Game{Board: strings.Join(moves, "")}, position), nil
+	})
+}
+
+// playMove records the move made by opponent at position and if the game is still running makes the computer move,
+// unless the game is played between humans.
+// The board and status of the stored game are updated and the moves made are returned
+func playMove(storedState *repository.Game, curGame *Game, position int) []repository.Move {
+	moves := []repository.Move{curGame.newMove(position, playerHuman)}
+
+	// If game is in RUNNING state then make our move.
+	// If not running then opponent has either won or drawn
+	status := curGame.getStatus()
+	if status == gameStatusRunning && storedState.Mode != gameModeHuman {
+		// game is running and now computer can make its move
+		position := curGame.play(storedState.ComputerMark, storedState.Strategy, storedState.Difficulty, storedState.Seed)
+		moves = append(moves, curGame.newMove(position, playerComputer))
+		status = curGame.getStatus()
+	}
+	storedState.Board = curGame.Board
+	storedState.Status = status
+	return moves
+}
+
+// sendPlayedGame sends the game after a move was played or the reason why it could not be played
+func (h *Handlers) sendPlayedGame(rw http.ResponseWriter, r *http.Request, gameID string, game *repository.Game, err error) {
+	if apiErr, ok := err.(*apiError); ok {
+		sendError(rw, apiErr)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("game update failed", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if game == nil {
+		logging.FromContext(r.Context()).Error("game not found", zap.String("gameid", gameID))
+		sendError(rw, gameNotFound())
+		return
+	}
+	h.played(game)
+	rw.Header().Set("ETag", etag(game.Version))
+	json.NewEncoder(rw).Encode(game)
+}
+
+// played counts the games ended by a move and publishes the move
+func (h *Handlers) played(game *repository.Game) {
+	h.hub.Publish(events.MoveMade, game.ID, game)
+	// moves are only played on running games, so the game ended with this move
+	if game.Status != gameStatusRunning {
+		strategy := game.Strategy
+		if game.Mode == gameModeHuman {
+			strategy = metricsNone
+		}
+		metrics.GamesFinished.WithLabelValues(game.Status, strategy).Inc()
+		h.hub.Publish(events.GameFinished, game.ID, game)
+	}
+}
+
+// GetMovesHandler returns the moves made in a game in the order they were played
+func (h *Handlers) GetMovesHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	gameID := mux.Vars(r)["game_id"]
+	game, err := h.repo.GetGame(r.Context(), gameID)
+	if err != nil {
+		logger.Error("unable to get game", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	// game not found
+	if game == nil {
+		logger.Error("game not found", zap.String("gameid", gameID))
+		sendError(rw, gameNotFound())
 		return
 	}
-	//Check if game exists
-	storedState, err := h.repo.GetGame(gameID)
+	moves, err := h.repo.GetMoves(r.Context(), gameID)
+	if err != nil {
+		logger.Error("unable to get moves", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	json.NewEncoder(rw).Encode(moves)
+}
+
+// UndoGameHandler takes back the last move of the opponent along with the computer reply to it.
+// Moves cannot be taken back in games between humans
+func (h *Handlers) UndoGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	gameID := mux.Vars(r)["game_id"]
+	storedState, err := h.repo.GetGame(r.Context(), gameID)
 	if err != nil {
 		logger.Error("unable to get game", zap.Error(err), zap.String("gameid", gameID))
-		rw.WriteHeader(http.StatusInternalServerError)
+		sendError(rw, repositoryError(err))
 		return
 	}
 	if storedState == nil {
 		logger.Error("game not found", zap.String("gameid", gameID))
-		rw.WriteHeader(http.StatusNotFound)
+		sendError(rw, gameNotFound())
+		return
+	}
+	if storedState.Mode == gameModeHuman {
+		logger.Error("undo in game between humans", zap.String("gameid", gameID))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeUndoNotSupported, "moves cannot be taken back in a game between humans"))
+		return
+	}
+	// Check if the client has the latest state of the game
+	if !matchesETag(r.Header.Get("If-Match"), storedState) {
+		logger.Error("game state is stale", zap.String("gameid", gameID), zap.String("if-match", r.Header.Get("If-Match")))
+		sendError(rw, newAPIError(http.StatusPreconditionFailed, codeGameModified, "game has been modified"))
 		return
 	}
-	// Check if game is still in play as per stored state
 	if storedState.Status != gameStatusRunning {
-		logger.Error("game already over", zap.Error(err))
-		sendJSONError(rw, http.StatusBadRequest, "game already over")
+		logger.Error("game already over", zap.String("gameid", gameID))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeGameOver, "game already over"))
 		return
 	}
-	//Check if play made by opponent is valid. Compare the game with the previous stat
-	playStatus := curGame.validatePlay(&Game{
-		Board: storedState.Board,
-	}, storedState.ComputerMark)
-	if playStatus == 0 {
-		logger.Error("no move made by opponent", zap.String("gameid", gameID))
-		sendJSONError(rw, http.StatusBadRequest, "no move made")
+	if storedState.Undos >= h.undoLimit {
+		logger.Error("undo limit reached", zap.String("gameid", gameID), zap.Int("undos", storedState.Undos))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeUndoLimitReached, "undo limit reached"))
 		return
 	}
-	if playStatus == -1 {
-		logger.Error("game state mismatch", zap.String("gameid", gameID))
-		sendJSONError(rw, http.StatusBadRequest, "game state mismatch")
+	moves, err := h.repo.GetMoves(r.Context(), gameID)
+	if err != nil {
+		logger.Error("unable to get moves", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
 		return
 	}
-
-	// If game is in RUNNING state then make our move.
-	status := curGame.getStatus()
-	// If not running then opponent has either won or drawn
-	if status != gameStatusRunning {
-		dbGame := &repository.Game{
-			ID:     gameID,
-			Board:  curGame.Board,
-			Status: status,
-		}
-		recordsAffected, err := h.repo.UpdateGame(dbGame)
-		if err != nil {
-			logger.Error("game update failed", zap.Error(err))
-			rw.WriteHeader(http.StatusInternalServerError)
-			return
+	// find the last move made by the opponent. Everything from there on is taken back
+	lastHumanMove := -1
+	for indx := len(moves) - 1; indx >= 0; indx-- {
+		if moves[indx].Player == playerHuman {
+			lastHumanMove = indx
+			break
 		}
-		// recordsAffected will be 0 only when the game gets deleted during the time the computer is checking the status. This is an extreme corner case and will occur in rare scenario.
-		if recordsAffected == 0 {
-			logger.Error("game not found", zap.String("gameid", gameID))
-			rw.WriteHeader(http.StatusNotFound)
-			return
-		}
-		json.NewEncoder(rw).Encode(dbGame)
+	}
+	if lastHumanMove == -1 {
+		logger.Error("no moves to undo", zap.String("gameid", gameID))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeNothingToUndo, "no moves to undo"))
 		return
 	}
-	// game is running and now computer can make its move
-	curGame.play(storedState.ComputerMark)
-	status = curGame.getStatus()
-	dbGame := &repository.Game{
-		ID:     gameID,
-		Board:  curGame.Board,
-		Status: status,
+	board := strings.Split(storedState.Board, "")
+	for _, move := range moves[lastHumanMove:] {
+		board[move.Position] = fMark
+	}
+	dbGame := *storedState
+	dbGame.Board = strings.Join(board, "")
+	dbGame.Status = gameStatusRunning
+	dbGame.Undos++
+	recordsAffected, err := h.repo.UndoMoves(r.Context(), &dbGame, moves[lastHumanMove].Ply)
+	// the game got updated by another request after it was read
+	if err == repository.ErrVersionMismatch {
+		logger.Error("game undo conflict", zap.String("gameid", gameID))
+		sendError(rw, newAPIError(http.StatusConflict, codeGameModified, "game has been modified"))
+		return
 	}
-	recordsAffected, err := h.repo.UpdateGame(dbGame)
 	if err != nil {
-		logger.Error("game update failed", zap.Error(err))
-		rw.WriteHeader(http.StatusInternalServerError)
+		logger.Error("game undo failed", zap.Error(err))
+		sendError(rw, repositoryError(err))
 		return
 	}
-	// recordsAffected will be 0 only when the game gets deleted during the time the computer is deciding to make a move. This is a corner case and will occur in rare scenario.
+	// recordsAffected will be 0 only when the game gets deleted in the meantime
 	if recordsAffected == 0 {
 		logger.Error("game not found", zap.String("gameid", gameID))
-		rw.WriteHeader(http.StatusNotFound)
+		sendError(rw, gameNotFound())
 		return
 	}
+	h.hub.Publish(events.MovesUndone, gameID, &dbGame)
+	rw.Header().Set("ETag", etag(dbGame.Version))
 	json.NewEncoder(rw).Encode(dbGame)
-	return
 }
 
 // DeleteGameHandler deletes a game from the db
 func (h *Handlers) DeleteGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
 	rw.Header().Set("Content-Type", "application/json")
 	params := mux.Vars(r)
-	rowsAffected, err := h.repo.DeleteGame(params["game_id"])
+	rowsAffected, err := h.repo.DeleteGame(r.Context(), params["game_id"])
 	if err != nil {
 		logger.Error("game deletion failed", zap.Error(err))
-		rw.WriteHeader(http.StatusInternalServerError)
+		sendError(rw, repositoryError(err))
 		return
 	}
 	if rowsAffected == 0 {
 		logger.Error("game not found", zap.String("gameid", params["game_id"]))
-		rw.WriteHeader(http.StatusNotFound)
+		sendError(rw, gameNotFound())
 		return
 	}
+	h.hub.Publish(events.GameDeleted, params["game_id"], nil)
 }
 
-// Change this to send response
-// For Bad Request create a struct
-func sendJSONError(rw http.ResponseWriter, code int, reason string) {
-	errorResp := map[string]string{"reason": reason}
-	rw.WriteHeader(code)
-	json.NewEncoder(rw).Encode(errorResp)
+// gameLocation returns the URL of the game
+func (h *Handlers) gameLocation(gameID string) string {
+	return fmt.Sprintf("%s/%s/%s", h.hostAddress, "api/v1/games", gameID)
+}
+
+// etag returns the entity tag of the given game version
+func etag(version int) string {
+	return strconv.Quote(strconv.Itoa(version))
+}
+
+// matchesETag checks the If-Match header of a request against the stored game.
+// Requests without If-Match are always allowed
+func matchesETag(ifMatch string, game *repository.Game) bool {
+	if len(ifMatch) == 0 {
+		return true
+	}
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag(game.Version) {
+			return true
+		}
+	}
+	return false
 }
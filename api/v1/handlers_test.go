@@ -2,50 +2,107 @@ package v1
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
 	"github.com/sunilkumarmohanty/tictactoe/repository"
 )
 
+var (
+	createdAt = time.Date(2018, 6, 12, 20, 51, 0, 0, time.UTC)
+	updatedAt = time.Date(2018, 6, 12, 20, 52, 0, 0, time.UTC)
+)
+
 type mockDB struct {
 	rowsAffected int64
 
-	gameID string
+	gameID  string
+	newGame *repository.Game    // game passed while inserting
+	moves   []repository.Move   // moves passed while inserting or updating
+	players []repository.Player // players passed while inserting
+
+	game      *repository.Game
+	games     []repository.Game
+	gameMoves []repository.Move
+
+	undoFromPly int // ply passed while taking back moves
 
-	game  *repository.Game
-	games []repository.Game
+	filter     *repository.GameFilter // filter passed while getting games
+	nextCursor string
 
-	deleteErr     error // error while deleting
-	newErr        error // error while inserting
-	getGameErr    error // error while getting a game
-	getGamesErr   error // error while getting all games
-	updateGameErr error // error while updating a game
+	deleteErr   error // error while deleting
+	newErr      error // error while inserting
+	getGameErr  error // error while getting a game
+	getGamesErr error // error while getting all games
+	playMoveErr error // error while saving a move
+	getMovesErr error // error while getting moves of a game
+	undoErr     error // error while taking back moves
 	IRepository
 }
 
-func (m *mockDB) DeleteGame(string) (int64, error) {
+func (m *mockDB) DeleteGame(context.Context, string) (int64, error) {
 	return m.rowsAffected, m.deleteErr
 }
-func (m *mockDB) NewGame(string, string) (string, error) {
+func (m *mockDB) NewGame(_ context.Context, game *repository.Game, moves []repository.Move, players []repository.Player) (string, error) {
+	m.newGame = game
+	m.moves = moves
+	m.players = players
 	return m.gameID, m.newErr
 }
-func (m *mockDB) GetGame(string) (*repository.Game, error) {
+func (m *mockDB) GetGame(context.Context, string) (*repository.Game, error) {
 	return m.game, m.getGameErr
 }
 
-func (m *mockDB) GetGames() ([]repository.Game, error) {
-	return m.games, m.getGamesErr
+func (m *mockDB) GetGames(_ context.Context, filter *repository.GameFilter) (*repository.GamePage, error) {
+	m.filter = filter
+	if m.getGamesErr != nil {
+		return nil, m.getGamesErr
+	}
+	return &repository.GamePage{
+		Games:      m.games,
+		NextCursor: m.nextCursor,
+		Total:      len(m.games),
+	}, nil
+}
+
+func (m *mockDB) PlayMove(_ context.Context, gameID string, play func(*repository.Game) ([]repository.Move, error)) (*repository.Game, error) {
+	if m.getGameErr != nil {
+		return nil, m.getGameErr
+	}
+	if m.game == nil {
+		return nil, nil
+	}
+	game := *m.game
+	moves, err := play(&game)
+	if err != nil {
+		return nil, err
+	}
+	m.moves = moves
+	if m.playMoveErr != nil {
+		return nil, m.playMoveErr
+	}
+	game.Version++
+	return &game, nil
+}
+
+func (m *mockDB) GetMoves(context.Context, string) ([]repository.Move, error) {
+	return m.gameMoves, m.getMovesErr
 }
 
-func (m *mockDB) UpdateGame(*repository.Game) (int64, error) {
-	return m.rowsAffected, m.updateGameErr
+func (m *mockDB) UndoMoves(_ context.Context, game *repository.Game, fromPly int) (int64, error) {
+	m.undoFromPly = fromPly
+	return m.rowsAffected, m.undoErr
 }
 func TestHandlers_DeleteGameHandler(t *testing.T) {
 
@@ -134,6 +191,8 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 		fields           fields
 		wantStatusCode   int
 		wantResponseBody string
+		wantBoard        string
+		wantMoves        []repository.Move
 	}{
 		{
 			name: "Valid",
@@ -144,6 +203,20 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 			wantStatusCode:   http.StatusCreated,
 			wantResponseBody: `{"location":"` + hostURL + `/api/v1/games/dummy_game_id"}`,
 		},
+		{
+			name: "Valid Seeded",
+			fields: fields{
+				dbGameID: "dummy_game_id",
+				body:     `{"board": "--------X", "seed": 42}`,
+			},
+			wantStatusCode:   http.StatusCreated,
+			wantResponseBody: `{"location":"` + hostURL + `/api/v1/games/dummy_game_id"}`,
+			wantBoard:        "-O------X",
+			wantMoves: []repository.Move{
+				{Ply: 1, Mark: "X", Position: 8, Player: "human"},
+				{Ply: 2, Mark: "O", Position: 1, Player: "computer"},
+			},
+		},
 		{
 			name: "Valid Blank Board",
 			fields: fields{
@@ -153,13 +226,48 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 			wantStatusCode:   http.StatusCreated,
 			wantResponseBody: `{"location":"` + hostURL + `/api/v1/games/dummy_game_id"}`,
 		},
+		{
+			name: "Valid Minimax Strategy",
+			fields: fields{
+				dbGameID: "dummy_game_id",
+				body:     `{"board": "--------X", "strategy": "minimax"}`,
+			},
+			wantStatusCode:   http.StatusCreated,
+			wantResponseBody: `{"location":"` + hostURL + `/api/v1/games/dummy_game_id"}`,
+		},
+		{
+			name: "Valid Difficulty",
+			fields: fields{
+				dbGameID: "dummy_game_id",
+				body:     `{"board": "--------X", "difficulty": "medium"}`,
+			},
+			wantStatusCode:   http.StatusCreated,
+			wantResponseBody: `{"location":"` + hostURL + `/api/v1/games/dummy_game_id"}`,
+		},
+		{
+			name: "Invalid Difficulty",
+			fields: fields{
+				body: `{"board": "--------X", "difficulty": "impossible"}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_DIFFICULTY","detail":"invalid difficulty","field":"difficulty"}`,
+		},
+		{
+			name: "Invalid Strategy",
+			fields: fields{
+				body: `{"board": "--------X", "strategy": "cheat"}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_STRATEGY","detail":"invalid strategy","field":"strategy"}`,
+		},
 		{
 			name: "Error from DB",
 			fields: fields{
 				body:     `{"board": "--------X"}`,
 				dbNewErr: errors.New("delete error"),
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
 		},
 		{
 			name: "Invalid move - more than one-X",
@@ -167,7 +275,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------XX"}`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid new board"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_BOARD","detail":"board has 2 moves, at most 1 allowed","field":"board","violation":"TOO_MANY_MOVES"}`,
 		},
 		{
 			name: "Invalid move - more than one-O",
@@ -175,7 +283,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------OO"}`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid new board"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_BOARD","detail":"board has 2 moves, at most 1 allowed","field":"board","violation":"TOO_MANY_MOVES"}`,
 		},
 		{
 			name: "Invalid move - more than one-OX",
@@ -183,7 +291,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------OX"}`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid new board"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_BOARD","detail":"board has 2 moves, at most 1 allowed","field":"board","violation":"TOO_MANY_MOVES"}`,
 		},
 		{
 			name: "Invalid move - board size wrong",
@@ -191,7 +299,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------XX-"}`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid new board"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_BOARD","detail":"board has 10 cells, 9 required","field":"board","violation":"BOARD_LENGTH"}`,
 		},
 		{
 			name: "Invalid move - invalid mark",
@@ -199,7 +307,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------V-"}`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid new board"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_MARK","detail":"unknown mark \"V\" at position 7","field":"board","position":7,"violation":"UNKNOWN_MARK"}`,
 		},
 		{
 			name: "Invalid json request body",
@@ -207,7 +315,7 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 				body: `{"board": "-------XX"`,
 			},
 			wantStatusCode:   http.StatusBadRequest,
-			wantResponseBody: `{"reason":"invalid request body"}`,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_REQUEST","detail":"invalid request body","field":"body"}`,
 		},
 	}
 	for _, tt := range tests {
@@ -231,7 +339,12 @@ func TestHandlers_CreateGameHandler(t *testing.T) {
 			if gotBody != tt.wantResponseBody {
 				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
 			}
-
+			if tt.wantBoard != "" && mockRepo.newGame.Board != tt.wantBoard {
+				t.Errorf("saved board did not match : got %v want %v", mockRepo.newGame.Board, tt.wantBoard)
+			}
+			if tt.wantMoves != nil && !reflect.DeepEqual(mockRepo.moves, tt.wantMoves) {
+				t.Errorf("saved moves did not match : got %v want %v", mockRepo.moves, tt.wantMoves)
+			}
 		})
 	}
 }
@@ -254,6 +367,7 @@ func TestHandlers_GetGameHandler(t *testing.T) {
 		fields           fields
 		wantStatusCode   int
 		wantResponseBody string
+		wantETag         string
 	}{
 		{
 			name: "Valid",
@@ -264,10 +378,14 @@ func TestHandlers_GetGameHandler(t *testing.T) {
 					Board:        "X--------",
 					Status:       "RUNNING",
 					ComputerMark: "X",
+					Version:      4,
+					CreatedAt:    createdAt,
+					UpdatedAt:    updatedAt,
 				},
 			},
 			wantStatusCode:   http.StatusOK,
-			wantResponseBody: `{"id":"dummy_game_id","board":"X--------","status":"RUNNING"}`,
+			wantResponseBody: `{"id":"dummy_game_id","board":"X--------","status":"RUNNING","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantETag:         `"4"`,
 		},
 		{
 			name: "Error from DB",
@@ -276,14 +394,34 @@ func TestHandlers_GetGameHandler(t *testing.T) {
 				gameID:       "dummy_game_id",
 				dbGetGameErr: errors.New("get game error"),
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "Timeout from DB",
+			fields: fields{
+				gameID:       "dummy_game_id",
+				dbGetGameErr: context.DeadlineExceeded,
+			},
+			wantStatusCode:   http.StatusGatewayTimeout,
+			wantResponseBody: `{"type":"about:blank","title":"Gateway Timeout","status":504,"code":"TIMEOUT","detail":"database call timed out"}`,
+		},
+		{
+			name: "Request Cancelled",
+			fields: fields{
+				gameID:       "dummy_game_id",
+				dbGetGameErr: context.Canceled,
+			},
+			wantStatusCode:   http.StatusServiceUnavailable,
+			wantResponseBody: `{"type":"about:blank","title":"Service Unavailable","status":503,"code":"UNAVAILABLE","detail":"request cancelled"}`,
 		},
 		{
 			name: "No game returned",
 			fields: fields{
 				gameID: "dummy_game_id",
 			},
-			wantStatusCode: http.StatusNotFound,
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
 		},
 	}
 	for _, tt := range tests {
@@ -304,6 +442,9 @@ func TestHandlers_GetGameHandler(t *testing.T) {
 			if recorder.Code != tt.wantStatusCode {
 				t.Errorf("status code did not match : got %v want %v", recorder.Code, tt.wantStatusCode)
 			}
+			if gotETag := recorder.Header().Get("ETag"); gotETag != tt.wantETag {
+				t.Errorf("etag did not match : got %v want %v", gotETag, tt.wantETag)
+			}
 			gotBody := strings.TrimSpace(recorder.Body.String())
 			if gotBody != tt.wantResponseBody {
 				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
@@ -318,7 +459,9 @@ func TestHandlers_GetAllGamesHandler(t *testing.T) {
 	m := mux.NewRouter()
 	m.HandleFunc("/api/v1/games", mockHandler.GetAllGamesHandler)
 	type fields struct {
+		query         string
 		dbGames       []repository.Game
+		dbNextCursor  string
 		dbGetGamesErr error
 	}
 	type args struct {
@@ -328,6 +471,7 @@ func TestHandlers_GetAllGamesHandler(t *testing.T) {
 		fields           fields
 		wantStatusCode   int
 		wantResponseBody string
+		wantFilter       *repository.GameFilter
 	}{
 		{
 			name: "Valid",
@@ -338,17 +482,117 @@ func TestHandlers_GetAllGamesHandler(t *testing.T) {
 						Board:        "X--------",
 						Status:       "RUNNING",
 						ComputerMark: "X",
+						CreatedAt:    createdAt,
+						UpdatedAt:    updatedAt,
 					},
 					repository.Game{
 						ID:           "dummy_game_id_2",
-						Board:        "X--------",
-						Status:       "RUNNING",
+						Board:        "XOX-O-OXX",
+						Status:       "X_WON",
 						ComputerMark: "X",
+						CreatedAt:    createdAt,
+						UpdatedAt:    updatedAt,
+						FinishedAt:   &updatedAt,
 					},
 				},
+				dbNextCursor: "dummy_cursor",
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"games":[{"id":"dummy_game_id_1","board":"X--------","status":"RUNNING","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"},{"id":"dummy_game_id_2","board":"XOX-O-OXX","status":"X_WON","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z","finished_at":"2018-06-12T20:52:00Z"}],"next_cursor":"dummy_cursor","total":2}`,
+			wantFilter: &repository.GameFilter{
+				Limit: defaultPageSize,
+				Sort:  repository.SortCreatedDesc,
+			},
+		},
+		{
+			name: "Valid Filters",
+			fields: fields{
+				query: "?limit=5&cursor=dummy_cursor&status=X_WON,DRAW&computer_mark=O&created_after=2018-06-12T00:00:00Z&created_before=2018-06-13T00:00:00Z&updated_after=2018-06-14T00:00:00Z&updated_before=2018-06-15T00:00:00Z&finished_after=2018-06-16T00:00:00Z&finished_before=2018-06-17T00:00:00Z&sort=created_at",
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"games":null,"total":0}`,
+			wantFilter: &repository.GameFilter{
+				Statuses:       []string{gameStatusXWon, gameStatusDraw},
+				ComputerMark:   "O",
+				CreatedAfter:   time.Date(2018, 6, 12, 0, 0, 0, 0, time.UTC),
+				CreatedBefore:  time.Date(2018, 6, 13, 0, 0, 0, 0, time.UTC),
+				UpdatedAfter:   time.Date(2018, 6, 14, 0, 0, 0, 0, time.UTC),
+				UpdatedBefore:  time.Date(2018, 6, 15, 0, 0, 0, 0, time.UTC),
+				FinishedAfter:  time.Date(2018, 6, 16, 0, 0, 0, 0, time.UTC),
+				FinishedBefore: time.Date(2018, 6, 17, 0, 0, 0, 0, time.UTC),
+				Sort:           repository.SortCreatedAsc,
+				Limit:          5,
+				Cursor:         "dummy_cursor",
+			},
+		},
+		{
+			name: "Sort By Updated",
+			fields: fields{
+				query: "?sort=-updated_at",
 			},
 			wantStatusCode:   http.StatusOK,
-			wantResponseBody: `[{"id":"dummy_game_id_1","board":"X--------","status":"RUNNING"},{"id":"dummy_game_id_2","board":"X--------","status":"RUNNING"}]`,
+			wantResponseBody: `{"games":null,"total":0}`,
+			wantFilter: &repository.GameFilter{
+				Sort:  repository.SortUpdatedDesc,
+				Limit: defaultPageSize,
+			},
+		},
+		{
+			name: "Invalid Limit",
+			fields: fields{
+				query: "?limit=101",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid limit","field":"limit"}`,
+		},
+		{
+			name: "Invalid Status",
+			fields: fields{
+				query: "?status=RUNNING,LOST",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid status","field":"status"}`,
+		},
+		{
+			name: "Invalid Computer Mark",
+			fields: fields{
+				query: "?computer_mark=Z",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid computer_mark","field":"computer_mark"}`,
+		},
+		{
+			name: "Invalid Created After",
+			fields: fields{
+				query: "?created_after=yesterday",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid created_after","field":"created_after"}`,
+		},
+		{
+			name: "Invalid Finished Before",
+			fields: fields{
+				query: "?finished_before=2018-06-17",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid finished_before","field":"finished_before"}`,
+		},
+		{
+			name: "Invalid Sort",
+			fields: fields{
+				query: "?sort=status",
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_PARAMETER","detail":"invalid sort","field":"sort"}`,
+		},
+		{
+			name: "Invalid Cursor",
+			fields: fields{
+				query:         "?cursor=dummy_cursor",
+				dbGetGamesErr: repository.ErrInvalidCursor,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_CURSOR","detail":"invalid cursor","field":"cursor"}`,
 		},
 		{
 			name: "Error from DB",
@@ -356,18 +600,20 @@ func TestHandlers_GetAllGamesHandler(t *testing.T) {
 			fields: fields{
 				dbGetGamesErr: errors.New("get games error"),
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &mockDB{
 				games:       tt.fields.dbGames,
+				nextCursor:  tt.fields.dbNextCursor,
 				getGamesErr: tt.fields.dbGetGamesErr,
 			}
 			mockHandler.repo = mockRepo
 
-			req, err := http.NewRequest("GET", hostURL, nil)
+			req, err := http.NewRequest("GET", hostURL+tt.fields.query, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -380,6 +626,9 @@ func TestHandlers_GetAllGamesHandler(t *testing.T) {
 			if gotBody != tt.wantResponseBody {
 				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
 			}
+			if tt.wantFilter != nil && !reflect.DeepEqual(mockRepo.filter, tt.wantFilter) {
+				t.Errorf("filter did not match : got %+v want %+v", mockRepo.filter, tt.wantFilter)
+			}
 		})
 	}
 }
@@ -390,13 +639,13 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 	m := mux.NewRouter()
 	m.HandleFunc("/api/v1/games/{game_id}", mockHandler.UpdateGameHandler)
 	type fields struct {
-		gameID string
-		body   string
+		gameID  string
+		body    string
+		ifMatch string
 
-		dbGame          *repository.Game
-		dbRowsAffected  int64
-		dbGetGameErr    error
-		dbUpdateGameErr error
+		dbGame        *repository.Game
+		dbGetGameErr  error
+		dbPlayMoveErr error
 	}
 	type args struct {
 	}
@@ -405,11 +654,14 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 		fields fields
 
 		wantGameStatuses    []string
+		wantBoard           string
+		wantMoves           []repository.Move
 		wantStatusCode      int
 		wantErrResponseBody string
+		wantETag            string
 	}{
 		{
-			name: "Valid",
+			name: "Valid Matching If-Match",
 			fields: fields{
 				gameID: "dummy_game_id",
 				dbGame: &repository.Game{
@@ -417,15 +669,17 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Board:        "--------X",
 					Status:       "RUNNING",
 					ComputerMark: "X",
+					Version:      2,
 				},
-				dbRowsAffected: 1,
-				body:           `{"board": "-------OX"}`,
+				body:    `{"board": "-------OX"}`,
+				ifMatch: `"2"`,
 			},
 			wantGameStatuses: []string{gameStatusRunning},
 			wantStatusCode:   http.StatusOK,
+			wantETag:         `"3"`,
 		},
 		{
-			name: "Valid Game Got Deleted While Making Move",
+			name: "Stale If-Match",
 			fields: fields{
 				gameID: "dummy_game_id",
 				dbGame: &repository.Game{
@@ -433,30 +687,54 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Board:        "--------X",
 					Status:       "RUNNING",
 					ComputerMark: "X",
+					Version:      2,
 				},
-				dbRowsAffected: 0,
-				body:           `{"board": "-------OX"}`,
+				body:    `{"board": "-------OX"}`,
+				ifMatch: `"1"`,
 			},
-			wantStatusCode: http.StatusNotFound,
+			wantStatusCode:      http.StatusPreconditionFailed,
+			wantErrResponseBody: `{"type":"about:blank","title":"Precondition Failed","status":412,"code":"GAME_MODIFIED","detail":"game has been modified"}`,
 		},
 		{
-			name: "Valid Opponent Win",
+			name: "Valid",
 			fields: fields{
 				gameID: "dummy_game_id",
 				dbGame: &repository.Game{
 					ID:           "dummy_game_id",
-					Board:        "OO-----XX",
+					Board:        "--------X",
 					Status:       "RUNNING",
 					ComputerMark: "X",
 				},
-				dbRowsAffected: 1,
-				body:           `{"board": "OOO----XX"}`,
+				body: `{"board": "-------OX"}`,
 			},
-			wantGameStatuses: []string{gameStatusOWon},
+			wantGameStatuses: []string{gameStatusRunning},
 			wantStatusCode:   http.StatusOK,
 		},
 		{
-			name: "Valid Opponent Win. Game Got Deleted While Checking Status ",
+			name: "Valid Seeded",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "--------X",
+					Status:       "RUNNING",
+					ComputerMark: "X",
+					Strategy:     "minimax",
+					Difficulty:   "medium",
+					Seed:         42,
+				},
+				body: `{"board": "-------OX"}`,
+			},
+			wantGameStatuses: []string{gameStatusRunning},
+			wantBoard:        "--X----OX",
+			wantMoves: []repository.Move{
+				{Ply: 2, Mark: "O", Position: 7, Player: "human"},
+				{Ply: 3, Mark: "X", Position: 2, Player: "computer"},
+			},
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			name: "Valid Opponent Win",
 			fields: fields{
 				gameID: "dummy_game_id",
 				dbGame: &repository.Game{
@@ -468,7 +746,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body: `{"board": "OOO----XX"}`,
 			},
 			wantGameStatuses: []string{gameStatusOWon},
-			wantStatusCode:   http.StatusNotFound,
+			wantStatusCode:   http.StatusOK,
 		},
 		{
 			name: "Valid Computer May Win",
@@ -480,12 +758,28 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Status:       "RUNNING",
 					ComputerMark: "X",
 				},
-				dbRowsAffected: 1,
-				body:           `{"board": "OO-----XX"}`,
+				body: `{"board": "OO-----XX"}`,
 			},
 			wantGameStatuses: []string{gameStatusXWon, gameStatusRunning},
 			wantStatusCode:   http.StatusOK,
 		},
+		{
+			name: "Valid Minimax Computer Wins",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "O------XX",
+					Status:       "RUNNING",
+					ComputerMark: "X",
+					Strategy:     "minimax",
+					Difficulty:   "perfect",
+				},
+				body: `{"board": "OO-----XX"}`,
+			},
+			wantGameStatuses: []string{gameStatusXWon},
+			wantStatusCode:   http.StatusOK,
+		},
 		{
 			name: "Valid Draw By Opponent",
 			fields: fields{
@@ -496,8 +790,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Status:       "RUNNING",
 					ComputerMark: "X",
 				},
-				dbRowsAffected: 1,
-				body:           `{"board": "OXXXOOOOX"}`,
+				body: `{"board": "OXXXOOOOX"}`,
 			},
 			wantGameStatuses: []string{gameStatusDraw},
 			wantStatusCode:   http.StatusOK,
@@ -509,7 +802,8 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				dbGetGameErr: errors.New("error getting game"),
 				body:         `{"board": "-------OX"}`,
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantErrResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
 		},
 		{
 			name: "Error From DB - Saving New Game",
@@ -521,10 +815,11 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Status:       "RUNNING",
 					ComputerMark: "X",
 				},
-				dbUpdateGameErr: errors.New("error updating game"),
-				body:            `{"board": "-------OX"}`,
+				dbPlayMoveErr: errors.New("error updating game"),
+				body:          `{"board": "-------OX"}`,
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantErrResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
 		},
 		{
 			name: "Error From DB - Saving New Game - Opponent Won",
@@ -536,10 +831,11 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 					Status:       "RUNNING",
 					ComputerMark: "X",
 				},
-				dbUpdateGameErr: errors.New("error updating game"),
-				body:            `{"board": "OOO----XX"}`,
+				dbPlayMoveErr: errors.New("error updating game"),
+				body:          `{"board": "OOO----XX"}`,
 			},
-			wantStatusCode: http.StatusInternalServerError,
+			wantStatusCode:      http.StatusInternalServerError,
+			wantErrResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
 		},
 		{
 			name: "No Game Returned From DB",
@@ -547,7 +843,8 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				gameID: "dummy_game_id",
 				body:   `{"board": "-------OX"}`,
 			},
-			wantStatusCode: http.StatusNotFound,
+			wantStatusCode:      http.StatusNotFound,
+			wantErrResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
 		},
 		{
 			name: "Invalid JSON Body",
@@ -556,7 +853,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body:   `"board": "-------OX"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"invalid request body"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_REQUEST","detail":"invalid request body","field":"body"}`,
 		},
 		{
 			name: "Invalid Board",
@@ -565,7 +862,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body:   `{"board": "-------OX-"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"invalid board"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_BOARD","detail":"invalid board","field":"board"}`,
 		},
 		{
 			name: "Invalid Mark In Board",
@@ -574,7 +871,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body:   `{"board": "-------OH"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"invalid board"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_MARK","detail":"invalid board","field":"board","position":8}`,
 		},
 		{
 			name: "No Move Made",
@@ -589,7 +886,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body: `{"board": "--------X"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"no move made"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"NO_MOVE","detail":"no move made","field":"board","violation":"NO_CHANGE"}`,
 		},
 		{
 			name: "Game Already Over",
@@ -604,7 +901,7 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body: `{"board": "XXXOOO---"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"game already over"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"GAME_OVER","detail":"game already over"}`,
 		},
 		{
 			name: "Invalid Move",
@@ -619,16 +916,15 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				body: `{"board": "------XOX"}`,
 			},
 			wantStatusCode:      http.StatusBadRequest,
-			wantErrResponseBody: `{"reason":"game state mismatch"}`,
+			wantErrResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_MARK","detail":"X played at position 6, the opponent plays O","field":"board","position":6,"violation":"WRONG_MARK"}`,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			mockRepo := &mockDB{
-				getGameErr:    tt.fields.dbGetGameErr,
-				updateGameErr: tt.fields.dbUpdateGameErr,
-				game:          tt.fields.dbGame,
-				rowsAffected:  tt.fields.dbRowsAffected,
+				getGameErr:  tt.fields.dbGetGameErr,
+				playMoveErr: tt.fields.dbPlayMoveErr,
+				game:        tt.fields.dbGame,
 			}
 			mockHandler.repo = mockRepo
 
@@ -637,11 +933,17 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 			if err != nil {
 				t.Fatal(err)
 			}
+			if len(tt.fields.ifMatch) != 0 {
+				req.Header.Set("If-Match", tt.fields.ifMatch)
+			}
 			recorder := httptest.NewRecorder()
 			m.ServeHTTP(recorder, req)
 			if recorder.Code != tt.wantStatusCode {
 				t.Errorf("status code did not match : got %v want %v", recorder.Code, tt.wantStatusCode)
 			}
+			if gotETag := recorder.Header().Get("ETag"); len(tt.wantETag) != 0 && gotETag != tt.wantETag {
+				t.Errorf("etag did not match : got %v want %v", gotETag, tt.wantETag)
+			}
 			gotBody := strings.TrimSpace(recorder.Body.String())
 
 			if recorder.Code != http.StatusOK {
@@ -662,6 +964,12 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				if !statusMatchFound {
 					t.Errorf("response game status did not match : got  %v want one of %v", game.Status, tt.wantGameStatuses)
 				}
+				if tt.wantBoard != "" && game.Board != tt.wantBoard {
+					t.Errorf("response game board did not match : got  %v want %v", game.Board, tt.wantBoard)
+				}
+				if tt.wantMoves != nil && !reflect.DeepEqual(mockRepo.moves, tt.wantMoves) {
+					t.Errorf("saved moves did not match : got %v want %v", mockRepo.moves, tt.wantMoves)
+				}
 
 				if tt.fields.gameID != game.ID {
 					t.Errorf("response game id did not match : got  %v want %v", game.ID, tt.fields.gameID)
@@ -672,9 +980,9 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 				json.Unmarshal([]byte(tt.fields.body), &oppGame)
 
 				if oppGame.getStatus() == gameStatusRunning {
-					computerGame := &Game{game.Board}
-					if computerGame.validatePlay(oppGame, findOpponentMark((tt.fields.dbGame.ComputerMark))) != 1 {
-						t.Errorf("invalid move made by computer")
+					computerGame := &Game{Board: game.Board}
+					if _, violation := computerGame.validatePlay(oppGame, findOpponentMark((tt.fields.dbGame.ComputerMark))); violation != nil {
+						t.Errorf("invalid move made by computer: %v", violation)
 					}
 				}
 			}
@@ -682,3 +990,559 @@ func TestHandlers_UpdateGameHandler(t *testing.T) {
 
 	}
 }
+
+func TestHandlers_GetMovesHandler(t *testing.T) {
+	mockHandler := &Handlers{}
+	hostURL := "http://tictactoe/api/v1/games"
+	m := mux.NewRouter()
+	m.HandleFunc("/api/v1/games/{game_id}/moves", mockHandler.GetMovesHandler)
+	type fields struct {
+		gameID        string
+		dbGame        *repository.Game
+		dbMoves       []repository.Move
+		dbGetGameErr  error
+		dbGetMovesErr error
+	}
+	tests := []struct {
+		name             string
+		fields           fields
+		wantStatusCode   int
+		wantResponseBody string
+	}{
+		{
+			name: "Valid",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "-O------X",
+					Status:       "RUNNING",
+					ComputerMark: "O",
+				},
+				dbMoves: []repository.Move{
+					{GameID: "dummy_game_id", Ply: 1, Mark: "X", Position: 8, Player: "human", CreatedAt: time.Date(2018, 6, 12, 20, 51, 0, 0, time.UTC)},
+					{GameID: "dummy_game_id", Ply: 2, Mark: "O", Position: 1, Player: "computer", CreatedAt: time.Date(2018, 6, 12, 20, 51, 0, 0, time.UTC)},
+				},
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `[{"ply":1,"mark":"X","position":8,"player":"human","created_at":"2018-06-12T20:51:00Z"},{"ply":2,"mark":"O","position":1,"player":"computer","created_at":"2018-06-12T20:51:00Z"}]`,
+		},
+		{
+			name: "Error from DB - Getting Game",
+			fields: fields{
+				gameID:       "dummy_game_id",
+				dbGetGameErr: errors.New("get game error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "Error from DB - Getting Moves",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID: "dummy_game_id",
+				},
+				dbGetMovesErr: errors.New("get moves error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "No game returned",
+			fields: fields{
+				gameID: "dummy_game_id",
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockDB{
+				game:        tt.fields.dbGame,
+				gameMoves:   tt.fields.dbMoves,
+				getGameErr:  tt.fields.dbGetGameErr,
+				getMovesErr: tt.fields.dbGetMovesErr,
+			}
+			mockHandler.repo = mockRepo
+
+			getMovesURL := fmt.Sprintf("%v/%v/moves", hostURL, tt.fields.gameID)
+			req, err := http.NewRequest("GET", getMovesURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			m.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("status code did not match : got %v want %v", recorder.Code, tt.wantStatusCode)
+			}
+			gotBody := strings.TrimSpace(recorder.Body.String())
+			if gotBody != tt.wantResponseBody {
+				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
+			}
+		})
+	}
+}
+
+func TestHandlers_UndoGameHandler(t *testing.T) {
+	mockHandler := &Handlers{
+		undoLimit: 2,
+	}
+	hostURL := "http://tictactoe/api/v1/games"
+	m := mux.NewRouter()
+	m.HandleFunc("/api/v1/games/{game_id}/undo", mockHandler.UndoGameHandler)
+	runningGame := &repository.Game{
+		ID:           "dummy_game_id",
+		Board:        "-O-X---OX",
+		Status:       "RUNNING",
+		ComputerMark: "O",
+		CreatedAt:    createdAt,
+		UpdatedAt:    updatedAt,
+	}
+	runningMoves := []repository.Move{
+		{Ply: 1, Mark: "X", Position: 8, Player: "human"},
+		{Ply: 2, Mark: "O", Position: 1, Player: "computer"},
+		{Ply: 3, Mark: "X", Position: 3, Player: "human"},
+		{Ply: 4, Mark: "O", Position: 7, Player: "computer"},
+	}
+	type fields struct {
+		gameID         string
+		dbGame         *repository.Game
+		dbMoves        []repository.Move
+		dbRowsAffected int64
+		dbGetGameErr   error
+		dbGetMovesErr  error
+		dbUndoErr      error
+	}
+	tests := []struct {
+		name             string
+		fields           fields
+		wantStatusCode   int
+		wantResponseBody string
+		wantFromPly      int
+	}{
+		{
+			name: "Valid",
+			fields: fields{
+				gameID:         "dummy_game_id",
+				dbGame:         runningGame,
+				dbMoves:        runningMoves,
+				dbRowsAffected: 1,
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":"dummy_game_id","board":"-O------X","status":"RUNNING","undos":1,"created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantFromPly:      3,
+		},
+		{
+			name: "Valid Without Computer Reply",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "-O-X----X",
+					Status:       "RUNNING",
+					ComputerMark: "O",
+					Undos:        1,
+					CreatedAt:    createdAt,
+					UpdatedAt:    updatedAt,
+				},
+				dbMoves:        runningMoves[:3],
+				dbRowsAffected: 1,
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":"dummy_game_id","board":"-O------X","status":"RUNNING","undos":2,"created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantFromPly:      3,
+		},
+		{
+			name: "Game Got Deleted While Taking Back",
+			fields: fields{
+				gameID:  "dummy_game_id",
+				dbGame:  runningGame,
+				dbMoves: runningMoves,
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
+			wantFromPly:      3,
+		},
+		{
+			name: "No Moves To Undo",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "X--------",
+					Status:       "RUNNING",
+					ComputerMark: "X",
+				},
+				dbMoves: []repository.Move{
+					{Ply: 1, Mark: "X", Position: 0, Player: "computer"},
+				},
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"NOTHING_TO_UNDO","detail":"no moves to undo"}`,
+		},
+		{
+			name: "Undo Limit Reached",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "-O-X---OX",
+					Status:       "RUNNING",
+					ComputerMark: "O",
+					Undos:        2,
+				},
+				dbMoves: runningMoves,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"UNDO_LIMIT_REACHED","detail":"undo limit reached"}`,
+		},
+		{
+			name: "Game Already Over",
+			fields: fields{
+				gameID: "dummy_game_id",
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "XXXOO----",
+					Status:       gameStatusXWon,
+					ComputerMark: "O",
+				},
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"GAME_OVER","detail":"game already over"}`,
+		},
+		{
+			name: "No Game Returned From DB",
+			fields: fields{
+				gameID: "dummy_game_id",
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
+		},
+		{
+			name: "Error from DB - Getting Game",
+			fields: fields{
+				gameID:       "dummy_game_id",
+				dbGetGameErr: errors.New("get game error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "Error from DB - Getting Moves",
+			fields: fields{
+				gameID:        "dummy_game_id",
+				dbGame:        runningGame,
+				dbGetMovesErr: errors.New("get moves error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "Error from DB - Taking Back",
+			fields: fields{
+				gameID:    "dummy_game_id",
+				dbGame:    runningGame,
+				dbMoves:   runningMoves,
+				dbUndoErr: errors.New("undo error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+			wantFromPly:      3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockDB{
+				game:         tt.fields.dbGame,
+				gameMoves:    tt.fields.dbMoves,
+				rowsAffected: tt.fields.dbRowsAffected,
+				getGameErr:   tt.fields.dbGetGameErr,
+				getMovesErr:  tt.fields.dbGetMovesErr,
+				undoErr:      tt.fields.dbUndoErr,
+			}
+			mockHandler.repo = mockRepo
+
+			undoURL := fmt.Sprintf("%v/%v/undo", hostURL, tt.fields.gameID)
+			req, err := http.NewRequest("POST", undoURL, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			m.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("status code did not match : got %v want %v", recorder.Code, tt.wantStatusCode)
+			}
+			gotBody := strings.TrimSpace(recorder.Body.String())
+			if gotBody != tt.wantResponseBody {
+				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
+			}
+			if mockRepo.undoFromPly != tt.wantFromPly {
+				t.Errorf("undo from ply did not match : got %v want %v", mockRepo.undoFromPly, tt.wantFromPly)
+			}
+		})
+	}
+}
+
+func TestHandlers_MakeMoveHandler(t *testing.T) {
+	mockHandler := &Handlers{}
+	hostURL := "http://tictactoe/api/v1/games"
+	m := mux.NewRouter()
+	m.HandleFunc("/api/v1/games/{game_id}/moves", mockHandler.MakeMoveHandler)
+	runningGame := &repository.Game{
+		ID:           "dummy_game_id",
+		Board:        "O------XX",
+		Status:       "RUNNING",
+		ComputerMark: "X",
+		Strategy:     "minimax",
+		Difficulty:   "perfect",
+		CreatedAt:    createdAt,
+		UpdatedAt:    updatedAt,
+	}
+	type fields struct {
+		gameID        string
+		body          string
+		dbGame        *repository.Game
+		dbGetGameErr  error
+		dbPlayMoveErr error
+	}
+	tests := []struct {
+		name             string
+		fields           fields
+		wantStatusCode   int
+		wantResponseBody string
+		wantMoves        []repository.Move
+	}{
+		{
+			name: "Valid Position",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 1}`,
+				dbGame: runningGame,
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":"dummy_game_id","board":"OO----XXX","status":"X_WON","strategy":"minimax","difficulty":"perfect","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantMoves: []repository.Move{
+				{Ply: 4, Mark: "O", Position: 1, Player: "human"},
+				{Ply: 5, Mark: "X", Position: 6, Player: "computer"},
+			},
+		},
+		{
+			name: "Valid Row And Col",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"row": 2, "col": 0}`,
+				dbGame: runningGame,
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":"dummy_game_id","board":"O--X--OXX","status":"RUNNING","strategy":"minimax","difficulty":"perfect","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantMoves: []repository.Move{
+				{Ply: 4, Mark: "O", Position: 6, Player: "human"},
+				{Ply: 5, Mark: "X", Position: 3, Player: "computer"},
+			},
+		},
+		{
+			name: "Valid Opponent Win",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 2}`,
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "OO-----XX",
+					Status:       "RUNNING",
+					ComputerMark: "X",
+					CreatedAt:    createdAt,
+					UpdatedAt:    updatedAt,
+				},
+			},
+			wantStatusCode:   http.StatusOK,
+			wantResponseBody: `{"id":"dummy_game_id","board":"OOO----XX","status":"O_WON","created_at":"2018-06-12T20:51:00Z","updated_at":"2018-06-12T20:52:00Z"}`,
+			wantMoves: []repository.Move{
+				{Ply: 5, Mark: "O", Position: 2, Player: "human"},
+			},
+		},
+		{
+			name: "Cell Occupied",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 0}`,
+				dbGame: runningGame,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"CELL_OCCUPIED","detail":"cell already occupied","position":0}`,
+		},
+		{
+			name: "Position Out Of Range",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 9}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_POSITION","detail":"position out of range","field":"position"}`,
+		},
+		{
+			name: "Row Out Of Range",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"row": 3, "col": 0}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_POSITION","detail":"row or col out of range","field":"row"}`,
+		},
+		{
+			name: "Missing Col",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"row": 1}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_POSITION","detail":"either position or row and col required","field":"position"}`,
+		},
+		{
+			name: "Both Position And Row",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 1, "row": 0, "col": 1}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_POSITION","detail":"either position or row and col required","field":"position"}`,
+		},
+		{
+			name: "Game Already Over",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 8}`,
+				dbGame: &repository.Game{
+					ID:           "dummy_game_id",
+					Board:        "XXXOO----",
+					Status:       gameStatusXWon,
+					ComputerMark: "X",
+				},
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"GAME_OVER","detail":"game already over"}`,
+		},
+		{
+			name: "Invalid JSON Body",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": "1"}`,
+			},
+			wantStatusCode:   http.StatusBadRequest,
+			wantResponseBody: `{"type":"about:blank","title":"Bad Request","status":400,"code":"INVALID_REQUEST","detail":"invalid request body","field":"body"}`,
+		},
+		{
+			name: "No Game Returned From DB",
+			fields: fields{
+				gameID: "dummy_game_id",
+				body:   `{"position": 1}`,
+			},
+			wantStatusCode:   http.StatusNotFound,
+			wantResponseBody: `{"type":"about:blank","title":"Not Found","status":404,"code":"GAME_NOT_FOUND","detail":"game not found"}`,
+		},
+		{
+			name: "Error from DB - Getting Game",
+			fields: fields{
+				gameID:       "dummy_game_id",
+				body:         `{"position": 1}`,
+				dbGetGameErr: errors.New("get game error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+		{
+			name: "Error from DB - Saving Game",
+			fields: fields{
+				gameID:        "dummy_game_id",
+				body:          `{"position": 1}`,
+				dbGame:        runningGame,
+				dbPlayMoveErr: errors.New("update game error"),
+			},
+			wantStatusCode:   http.StatusInternalServerError,
+			wantResponseBody: `{"type":"about:blank","title":"Internal Server Error","status":500,"code":"INTERNAL_ERROR","detail":"Internal server error"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockDB{
+				game:        tt.fields.dbGame,
+				getGameErr:  tt.fields.dbGetGameErr,
+				playMoveErr: tt.fields.dbPlayMoveErr,
+			}
+			mockHandler.repo = mockRepo
+
+			movesURL := fmt.Sprintf("%v/%v/moves", hostURL, tt.fields.gameID)
+			req, err := http.NewRequest("POST", movesURL, bytes.NewBuffer([]byte(tt.fields.body)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := httptest.NewRecorder()
+			m.ServeHTTP(recorder, req)
+			if recorder.Code != tt.wantStatusCode {
+				t.Errorf("status code did not match : got %v want %v", recorder.Code, tt.wantStatusCode)
+			}
+			gotBody := strings.TrimSpace(recorder.Body.String())
+			if gotBody != tt.wantResponseBody {
+				t.Errorf("response body did not match : got %v want %v", gotBody, tt.wantResponseBody)
+			}
+			if tt.wantMoves != nil && !reflect.DeepEqual(mockRepo.moves, tt.wantMoves) {
+				t.Errorf("saved moves did not match : got %v want %v", mockRepo.moves, tt.wantMoves)
+			}
+		})
+	}
+}
+
+// TestHandlers_gameMetrics checks that a game is counted once when created and once when it ends
+func TestHandlers_gameMetrics(t *testing.T) {
+	router := mux.NewRouter()
+	makeRoutes(router, &Handlers{repo: repository.NewMemory()})
+	do := func(method, target, body string, wantCode int) *repository.Game {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rw.Code != wantCode {
+			t.Fatalf("%v %v code = %v, want %v: %v", method, target, rw.Code, wantCode, rw.Body.String())
+		}
+		game := &repository.Game{}
+		json.NewDecoder(rw.Body).Decode(game)
+		return game
+	}
+	created := metrics.GamesCreated.WithLabelValues("O")
+	finished := metrics.GamesFinished.WithLabelValues(gameStatusDraw, "heuristic")
+	wantCreated, wantFinished := testutil.ToFloat64(created), testutil.ToFloat64(finished)
+	check := func(step string) {
+		t.Helper()
+		if got := testutil.ToFloat64(created); got != wantCreated {
+			t.Errorf("games created after %v = %v, want %v", step, got, wantCreated)
+		}
+		if got := testutil.ToFloat64(finished); got != wantFinished {
+			t.Errorf("games finished after %v = %v, want %v", step, got, wantFinished)
+		}
+	}
+
+	rw := httptest.NewRecorder()
+	router.ServeHTTP(rw, httptest.NewRequest("POST", "/api/v1/games", strings.NewReader(`{"board": "----X----", "strategy": "heuristic"}`)))
+	resp := newGameResponse{}
+	if err := json.NewDecoder(rw.Body).Decode(&resp); rw.Code != http.StatusCreated || err != nil {
+		t.Fatalf("creating game: code %v, err %v", rw.Code, err)
+	}
+	location := resp.Location
+	wantCreated++
+	check("creating a game")
+
+	for _, position := range []int{1, 3, 2} {
+		if game := do("POST", location+"/moves", fmt.Sprintf(`{"position": %v}`, position), http.StatusOK); game.Status != gameStatusRunning {
+			t.Fatalf("status after move %v = %v, want %v", position, game.Status, gameStatusRunning)
+		}
+		check("a move which did not end the game")
+	}
+	if game := do("POST", location+"/moves", `{"position": 8}`, http.StatusOK); game.Status != gameStatusDraw {
+		t.Fatalf("status after last move = %v, want %v", game.Status, gameStatusDraw)
+	}
+	wantFinished++
+	check("the finishing move")
+
+	do("GET", location, "", http.StatusOK)
+	check("getting the finished game")
+}
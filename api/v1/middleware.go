@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
+)
+
+// headerRequestID carries the ID which correlates the logs of a request
+const headerRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds the request IDs accepted from the clients
+const maxRequestIDLength = 128
+
+// instrument assigns an ID to the request and makes a logger tagged with it available to the handler and the repository
+// through the context of the request. Once the request is served, it logs a single access line and records the metrics of the route
+func instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		requestID := r.Header.Get(headerRequestID)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		rw.Header().Set(headerRequestID, requestID)
+		logger := logging.Logger().With(zap.String("request_id", requestID))
+		recorder := &statusRecorder{ResponseWriter: rw, code: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(logging.NewContext(r.Context(), logger)))
+
+		route := routeTemplate(r)
+		latency := time.Since(start)
+		fields := []zap.Field{
+			zap.String("method", r.Method),
+			zap.String("route", route),
+			zap.Int("status", recorder.code),
+			zap.Duration("latency", latency),
+		}
+		if gameID, ok := mux.Vars(r)["game_id"]; ok {
+			fields = append(fields, zap.String("game_id", gameID))
+		}
+		if webhookID, ok := mux.Vars(r)["webhook_id"]; ok {
+			fields = append(fields, zap.String("webhook_id", webhookID))
+		}
+		logger.Info("request served", fields...)
+		metrics.ObserveRequest(route, r.Method, recorder.code, latency)
+	})
+}
+
+// validRequestID reports if a request ID sent by the client can be used. Only short printable IDs are accepted
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+	return strings.IndexFunc(id, func(c rune) bool { return c < '!' || c > '~' }) == -1
+}
+
+// newRequestID returns a random request ID
+func newRequestID() string {
+	id := make([]byte, 16)
+	// crypto/rand only fails if the system has no source of randomness, the ID is then still usable for correlation
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// statusRecorder remembers the status code written to the response
+type statusRecorder struct {
+	http.ResponseWriter
+	code int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.code = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Flush lets the event streams send the events as they happen
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack lets the game socket take over the connection
+func (r *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err == nil {
+		r.code = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// routeTemplate returns the path template of the route matching the request without the patterns of its variables,
+// e.g. /api/v1/games/{game_id}, so that it identifies the route whatever the game
+func routeTemplate(r *http.Request) string {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "unknown"
+	}
+	template, err := route.GetPathTemplate()
+	if err != nil {
+		return "unknown"
+	}
+	var label strings.Builder
+	depth := 0
+	inPattern := false
+	for _, c := range template {
+		switch {
+		case c == '{':
+			depth++
+			if depth > 1 {
+				continue
+			}
+		case c == '}':
+			depth--
+			if depth > 0 {
+				continue
+			}
+			inPattern = false
+		case c == ':' && depth == 1:
+			inPattern = true
+		}
+		if !inPattern {
+			label.WriteRune(c)
+		}
+	}
+	return label.String()
+}
@@ -0,0 +1,106 @@
+package v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+)
+
+func Test_instrument(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(instrument)
+	router.Path("/games/{game_id:[a-f0-9]{8}}").Methods("GET").HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		if logging.FromContext(r.Context()) == logging.Logger() {
+			t.Errorf("FromContext() = service logger, want request logger")
+		}
+		rw.WriteHeader(http.StatusNotFound)
+	})
+	tests := []struct {
+		name      string
+		requestID string
+		want      string
+	}{
+		{
+			name:      "Propagated",
+			requestID: "client-request-1",
+			want:      "client-request-1",
+		},
+		{
+			name: "Assigned",
+		},
+		{
+			name:      "Invalid Replaced",
+			requestID: "two words",
+		},
+		{
+			name:      "Too Long Replaced",
+			requestID: strings.Repeat("a", maxRequestIDLength+1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/games/0123abcd", nil)
+			if len(tt.requestID) != 0 {
+				r.Header.Set(headerRequestID, tt.requestID)
+			}
+			rw := httptest.NewRecorder()
+			router.ServeHTTP(rw, r)
+			got := rw.Header().Get(headerRequestID)
+			if len(tt.want) != 0 && got != tt.want {
+				t.Errorf("instrument() request id = %v, want %v", got, tt.want)
+			}
+			if len(tt.want) == 0 && (len(got) != 32 || got == tt.requestID) {
+				t.Errorf("instrument() request id = %v, want a new id", got)
+			}
+			if rw.Code != http.StatusNotFound {
+				t.Errorf("instrument() code = %v, want %v", rw.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func Test_routeTemplate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		path     string
+		want     string
+	}{
+		{
+			name:     "Pattern Stripped",
+			template: "/games/{game_id:[a-f0-9]{8}-?[a-f0-9]{4}}/moves",
+			path:     "/games/0123abcd-4567/moves",
+			want:     "/games/{game_id}/moves",
+		},
+		{
+			name:     "No Variables",
+			template: "/games",
+			path:     "/games",
+			want:     "/games",
+		},
+		{
+			name:     "Variable Without Pattern",
+			template: "/games/{game_id}",
+			path:     "/games/dummy_game_id",
+			want:     "/games/{game_id}",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			router := mux.NewRouter()
+			router.Path(tt.template).HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+				got = routeTemplate(r)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", tt.path, nil))
+			if got != tt.want {
+				t.Errorf("routeTemplate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
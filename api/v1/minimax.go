@@ -0,0 +1,57 @@
+package v1
+
+import "math"
+
+// bestMove searches the complete game tree using minimax with alpha-beta pruning
+// and returns the position which gives mark the best possible result.
+// Returns -1 if there are no blank positions left
+func bestMove(moves []string, mark string) int {
+	board := make([]string, len(moves))
+	copy(board, moves)
+
+	bestPosition, bestScore := -1, math.MinInt32
+	for _, position := range findBlankPositions(board) {
+		board[position] = mark
+		score := minimax(board, mark, findOpponentMark(mark), 1, math.MinInt32, math.MaxInt32)
+		board[position] = fMark
+		if score > bestScore {
+			bestPosition, bestScore = position, score
+		}
+	}
+	return bestPosition
+}
+
+// minimax scores the board from the point of view of mark with turn to play next.
+// Quicker wins and slower losses are preferred by taking the depth into account
+func minimax(board []string, mark, turn string, depth, alpha, beta int) int {
+	switch findWinner(board) {
+	case mark:
+		return 10 - depth
+	case findOpponentMark(mark):
+		return depth - 10
+	}
+	blankPositions := findBlankPositions(board)
+	if len(blankPositions) == 0 {
+		return 0
+	}
+
+	maximising := turn == mark
+	for _, position := range blankPositions {
+		board[position] = turn
+		score := minimax(board, mark, findOpponentMark(turn), depth+1, alpha, beta)
+		board[position] = fMark
+		if maximising && score > alpha {
+			alpha = score
+		}
+		if !maximising && score < beta {
+			beta = score
+		}
+		if alpha >= beta {
+			break
+		}
+	}
+	if maximising {
+		return alpha
+	}
+	return beta
+}
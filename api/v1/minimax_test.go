@@ -0,0 +1,84 @@
+package v1
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_bestMove(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+		mark  string
+		want  int
+	}{
+		{
+			name:  "Complete Horizontal Win",
+			board: "XX-OO----",
+			mark:  xMark,
+			want:  2,
+		},
+		{
+			name:  "Complete Diagonal Win",
+			board: "O-X-O-X--",
+			mark:  oMark,
+			want:  8,
+		},
+		{
+			name:  "Block Vertical Win",
+			board: "X--X---O-",
+			mark:  oMark,
+			want:  6,
+		},
+		{
+			name:  "Prefer Win Over Block",
+			board: "OO-XX----",
+			mark:  xMark,
+			want:  5,
+		},
+		{
+			name:  "Full Board",
+			board: "OXXXOOOOX",
+			mark:  xMark,
+			want:  -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := bestMove(strings.Split(tt.board, ""), tt.mark); got != tt.want {
+				t.Errorf("bestMove() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Test_bestMove_NeverLoses plays the computer against every possible sequence of opponent moves
+func Test_bestMove_NeverLoses(t *testing.T) {
+	var playAll func(board []string, computerMark, turn string)
+	playAll = func(board []string, computerMark, turn string) {
+		switch findWinner(board) {
+		case findOpponentMark(computerMark):
+			t.Fatalf("computer lost the game %v", strings.Join(board, ""))
+		case computerMark:
+			return
+		}
+		blankPositions := findBlankPositions(board)
+		if len(blankPositions) == 0 {
+			return
+		}
+		if turn == computerMark {
+			position := bestMove(board, computerMark)
+			board[position] = computerMark
+			playAll(board, computerMark, findOpponentMark(turn))
+			board[position] = fMark
+			return
+		}
+		for _, position := range blankPositions {
+			board[position] = turn
+			playAll(board, computerMark, findOpponentMark(turn))
+			board[position] = fMark
+		}
+	}
+	playAll(strings.Split("---------", ""), xMark, xMark)
+	playAll(strings.Split("---------", ""), oMark, xMark)
+}
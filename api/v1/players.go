@@ -0,0 +1,219 @@
+package v1
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+const (
+	gameModeComputer = "computer" // a human plays against the computer
+	gameModeHuman    = "human"    // two humans play against each other, the computer never moves
+)
+
+// metricsNone labels the computer mark and strategy of the games between two humans in the metrics
+const metricsNone = "none"
+
+// tokenLength is the number of random bytes of a player token or a webhook secret
+const tokenLength = 32
+
+// createHumanGame creates a game between two humans from a blank board. The player creating it gets the requested mark,
+// X if none, along with its token. The strategy, difficulty and seed of the request are ignored
+func (h *Handlers) createHumanGame(rw http.ResponseWriter, r *http.Request, newGame *Game) {
+	logger := logging.FromContext(r.Context())
+	if newGame.Board == "" {
+		newGame.Board = strings.Repeat(fMark, 9)
+	}
+	if _, violation := newGame.validateNewGame(); violation != nil {
+		logger.Error("invalid new board", zap.String("board", newGame.Board), zap.String("violation", violation.kind), zap.Error(violation))
+		sendError(rw, violation.apiError())
+		return
+	}
+	if strings.ContainsAny(newGame.Board, xMark+oMark) {
+		violation := &boardViolation{kind: violationTooManyMoves, detail: "board has 1 move, a game between humans starts from a blank board"}
+		logger.Error("invalid new board", zap.String("board", newGame.Board), zap.String("violation", violation.kind))
+		sendError(rw, violation.apiError())
+		return
+	}
+	if newGame.Mark == "" {
+		newGame.Mark = xMark
+	}
+	if newGame.Mark != xMark && newGame.Mark != oMark {
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidMark, "mark must be X or O").withField("mark"))
+		return
+	}
+	token, err := newToken()
+	if err != nil {
+		logger.Error("unable to create player token", zap.Error(err))
+		sendError(rw, newAPIError(http.StatusInternalServerError, codeInternal, msgInternalServerError))
+		return
+	}
+	dbGame := &repository.Game{
+		Mode:  gameModeHuman,
+		Board: newGame.Board,
+	}
+	gameID, err := h.repo.NewGame(r.Context(), dbGame, nil, []repository.Player{{Mark: newGame.Mark, TokenHash: hashToken(token)}})
+	if err != nil {
+		logger.Error("game creation failed", zap.Error(err))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	logger.Info("game created", zap.String("game_id", gameID), zap.String("mode", gameModeHuman))
+	metrics.GamesCreated.WithLabelValues(metricsNone).Inc()
+	h.hub.Publish(events.GameCreated, gameID, dbGame)
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(newGameResponse{
+		Location: h.gameLocation(gameID),
+		Mark:     newGame.Mark,
+		Token:    token,
+	})
+}
+
+// JoinGameHandler lets the second player join a game between two humans. The player gets the mark left by the first player
+// along with its token
+func (h *Handlers) JoinGameHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	gameID := mux.Vars(r)["game_id"]
+	token, err := newToken()
+	if err != nil {
+		logger.Error("unable to create player token", zap.Error(err))
+		sendError(rw, newAPIError(http.StatusInternalServerError, codeInternal, msgInternalServerError))
+		return
+	}
+	player, err := h.repo.JoinGame(r.Context(), gameID, func(game *repository.Game, players []repository.Player) (*repository.Player, error) {
+		if game.Mode != gameModeHuman {
+			logger.Error("game is played against the computer", zap.String("gameid", gameID))
+			return nil, newAPIError(http.StatusBadRequest, codeNotMultiplayer, "game is played against the computer")
+		}
+		if game.Status != gameStatusRunning {
+			logger.Error("game already over", zap.String("gameid", gameID))
+			return nil, newAPIError(http.StatusBadRequest, codeGameOver, "game already over")
+		}
+		if len(players) != 1 {
+			logger.Error("game already full", zap.String("gameid", gameID))
+			return nil, newAPIError(http.StatusConflict, codeGameFull, "both players already joined")
+		}
+		return &repository.Player{Mark: findOpponentMark(players[0].Mark), TokenHash: hashToken(token)}, nil
+	})
+	if apiErr, ok := err.(*apiError); ok {
+		sendError(rw, apiErr)
+		return
+	}
+	if err != nil {
+		logger.Error("joining game failed", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if player == nil {
+		logger.Error("game not found", zap.String("gameid", gameID))
+		sendError(rw, gameNotFound())
+		return
+	}
+	logger.Info("player joined", zap.String("game_id", gameID), zap.String("mark", player.Mark))
+	json.NewEncoder(rw).Encode(newGameResponse{
+		Location: h.gameLocation(gameID),
+		Mark:     player.Mark,
+		Token:    token,
+	})
+}
+
+// client is the client making a move: the player token and If-Match it sent, and the player holding the token if any
+type client struct {
+	token   string
+	ifMatch string
+	player  *repository.Player
+}
+
+// requestClient returns the client making the request
+func (h *Handlers) requestClient(r *http.Request, gameID string) (*client, error) {
+	return h.newClient(r.Context(), gameID, playerToken(r), r.Header.Get("If-Match"))
+}
+
+// newClient returns the client sending the token and If-Match. The player is looked up only if there is a token
+func (h *Handlers) newClient(ctx context.Context, gameID string, token string, ifMatch string) (*client, error) {
+	c := &client{token: token, ifMatch: ifMatch}
+	if c.token == "" {
+		return c, nil
+	}
+	player, err := h.repo.GetPlayer(ctx, gameID, hashToken(c.token))
+	if err != nil {
+		return nil, err
+	}
+	c.player = player
+	return c, nil
+}
+
+// moveMark checks that the client may move in the stored game and returns the mark it plays.
+// Against the computer it is the mark the computer does not play. Between humans it is the mark of the player,
+// who must hold a token of the game and be the one to move
+func (c *client) moveMark(storedState *repository.Game) (string, *apiError) {
+	// Check if the client has the latest state of the game
+	if !matchesETag(c.ifMatch, storedState) {
+		return "", newAPIError(http.StatusPreconditionFailed, codeGameModified, "game has been modified")
+	}
+	// Check if game is still in play as per stored state
+	if storedState.Status != gameStatusRunning {
+		return "", newAPIError(http.StatusBadRequest, codeGameOver, "game already over")
+	}
+	if storedState.Mode != gameModeHuman {
+		return findOpponentMark(storedState.ComputerMark), nil
+	}
+	if c.token == "" {
+		return "", newAPIError(http.StatusUnauthorized, codePlayerTokenRequired, "player token required")
+	}
+	if c.player == nil {
+		return "", newAPIError(http.StatusForbidden, codeInvalidPlayerToken, "token is not one of a player of the game")
+	}
+	if turn := nextMark(storedState.Board); turn != c.player.Mark {
+		return "", newAPIError(http.StatusConflict, codeNotYourTurn, fmt.Sprintf("%s is to move", turn))
+	}
+	return c.player.Mark, nil
+}
+
+// nextMark returns the mark to move on the board of a game between humans, X moves first
+func nextMark(board string) string {
+	if strings.Count(board, xMark) > strings.Count(board, oMark) {
+		return oMark
+	}
+	return xMark
+}
+
+// playerToken returns the bearer token of the Authorization header of the request, empty if there is none
+func playerToken(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(auth[len(prefix):])
+}
+
+// newToken returns a random token, used for the player tokens and the webhook secrets
+func newToken() (string, error) {
+	token := make([]byte, tokenLength)
+	_, err := rand.Read(token)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// hashToken returns the hash of a player token, which is stored instead of the token
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// Test_humanGame plays a game between two humans end to end through the routes against the in-memory repository
+func Test_humanGame(t *testing.T) {
+	router := mux.NewRouter()
+	makeRoutes(router, &Handlers{repo: repository.NewMemory(), undoLimit: 3})
+	do := func(method, target, body, token string, wantCode int, wantErrCode string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if len(token) != 0 {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, r)
+		if rw.Code != wantCode {
+			t.Fatalf("%v %v code = %v, want %v: %v", method, target, rw.Code, wantCode, rw.Body.String())
+		}
+		if len(wantErrCode) != 0 {
+			got := problem{}
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil || got.Code != wantErrCode {
+				t.Fatalf("%v %v problem = %+v, %v, want %v", method, target, got, err, wantErrCode)
+			}
+		}
+		return rw
+	}
+	decodePlayer := func(rw *httptest.ResponseRecorder) newGameResponse {
+		t.Helper()
+		resp := newGameResponse{}
+		if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+			t.Fatalf("decoding player: %v", err)
+		}
+		return resp
+	}
+	decodeGame := func(rw *httptest.ResponseRecorder) *repository.Game {
+		t.Helper()
+		game := &repository.Game{}
+		if err := json.NewDecoder(rw.Body).Decode(game); err != nil {
+			t.Fatalf("decoding game: %v", err)
+		}
+		return game
+	}
+
+	do("POST", "/api/v1/games", `{"mode": "people"}`, "", http.StatusBadRequest, codeInvalidMode)
+	do("POST", "/api/v1/games", `{"mode": "human", "board": "----X----"}`, "", http.StatusBadRequest, codeInvalidBoard)
+	do("POST", "/api/v1/games", `{"mode": "human", "mark": "Z"}`, "", http.StatusBadRequest, codeInvalidMark)
+
+	o := decodePlayer(do("POST", "/api/v1/games", `{"mode": "human", "mark": "O"}`, "", http.StatusCreated, ""))
+	if o.Mark != oMark || len(o.Token) != 2*tokenLength {
+		t.Fatalf("creator = %+v, want mark O with a token", o)
+	}
+	location := o.Location
+	if game := decodeGame(do("GET", location, "", "", http.StatusOK, "")); game.Mode != gameModeHuman || game.Board != "---------" {
+		t.Errorf("new game = %v %v, want a blank game between humans", game.Mode, game.Board)
+	}
+
+	x := decodePlayer(do("POST", location+"/join", "", "", http.StatusOK, ""))
+	if x.Mark != xMark || x.Token == o.Token {
+		t.Fatalf("joined player = %+v, want mark X with a token of its own", x)
+	}
+	do("POST", location+"/join", "", "", http.StatusConflict, codeGameFull)
+
+	do("POST", location+"/moves", `{"position": 4}`, "", http.StatusUnauthorized, codePlayerTokenRequired)
+	do("POST", location+"/moves", `{"position": 4}`, "not-a-token", http.StatusForbidden, codeInvalidPlayerToken)
+	do("POST", location+"/moves", `{"position": 4}`, o.Token, http.StatusConflict, codeNotYourTurn)
+	if game := decodeGame(do("POST", location+"/moves", `{"position": 4}`, x.Token, http.StatusOK, "")); game.Board != "----X----" {
+		t.Errorf("board after move of X = %v, want ----X---- without a computer move", game.Board)
+	}
+	do("POST", location+"/moves", `{"position": 0}`, x.Token, http.StatusConflict, codeNotYourTurn)
+	do("PUT", location, `{"board": "X---X----"}`, o.Token, http.StatusBadRequest, codeInvalidMark)
+	if game := decodeGame(do("PUT", location, `{"board": "O---X----"}`, o.Token, http.StatusOK, "")); game.Board != "O---X----" {
+		t.Errorf("board after move of O = %v, want O---X----", game.Board)
+	}
+	do("POST", location+"/undo", "", x.Token, http.StatusBadRequest, codeUndoNotSupported)
+
+	for _, move := range []struct {
+		position int
+		token    string
+	}{{3, x.Token}, {6, o.Token}} {
+		do("POST", location+"/moves", `{"position": `+strconv.Itoa(move.position)+`}`, move.token, http.StatusOK, "")
+	}
+	game := decodeGame(do("GET", location, "", "", http.StatusOK, ""))
+	if game.Board != "O--XX-O--" || game.Status != gameStatusRunning {
+		t.Errorf("game = %v %v, want O--XX-O-- %v", game.Board, game.Status, gameStatusRunning)
+	}
+	if game := decodeGame(do("POST", location+"/moves", `{"position": 5}`, x.Token, http.StatusOK, "")); game.Status != gameStatusXWon {
+		t.Errorf("status after winning move = %v, want %v", game.Status, gameStatusXWon)
+	}
+	do("POST", location+"/moves", `{"position": 8}`, o.Token, http.StatusBadRequest, codeGameOver)
+
+	computer := decodePlayer(do("POST", "/api/v1/games", `{"board": "---------"}`, "", http.StatusCreated, ""))
+	do("POST", computer.Location+"/join", "", "", http.StatusBadRequest, codeNotMultiplayer)
+}
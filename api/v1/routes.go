@@ -1,19 +1,52 @@
 package v1
 
 import (
+	"context"
+
 	"github.com/gorilla/mux"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
 )
 
-// MakeHandlers creates all the routes and map it to respective handlers
-func MakeHandlers(router *mux.Router) {
-	uuidRegex := "[a-fA-F0-9]{8}-?[a-fA-F0-9]{4}-?4[a-fA-F0-9]{3}-?[8|9|aA|bB][a-fA-F0-9]{3}-?[a-fA-F0-9]{12}"
-	gameHandlers := New()
+// MakeHandlers creates all the routes and map it to respective handlers.
+// The handlers are returned so that they can be closed once the server is stopped
+func MakeHandlers(router *mux.Router, cfg *config.Config) (*Handlers, error) {
+	gameHandlers, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	makeRoutes(router, gameHandlers)
+	metrics.CountRunningGames(func() (int, error) {
+		return gameHandlers.repo.CountGames(context.Background(), &repository.GameFilter{Statuses: []string{"RUNNING"}})
+	})
+	return gameHandlers, nil
+}
 
+// makeRoutes maps the routes to the handlers
+func makeRoutes(router *mux.Router, gameHandlers *Handlers) {
+	uuidRegex := "[a-fA-F0-9]{8}-?[a-fA-F0-9]{4}-?4[a-fA-F0-9]{3}-?[8|9|aA|bB][a-fA-F0-9]{3}-?[a-fA-F0-9]{12}"
 	v1Router := router.PathPrefix("/api/v1").Subrouter()
+	v1Router.Use(instrument)
 
 	v1Router.Path("/games").Methods("GET").HandlerFunc(gameHandlers.GetAllGamesHandler)
 	v1Router.Path("/games").Methods("POST").HandlerFunc(gameHandlers.CreateGameHandler)
+	v1Router.Path("/events").Methods("GET").HandlerFunc(gameHandlers.EventsHandler)
+	v1Router.Path("/webhooks").Methods("GET").HandlerFunc(gameHandlers.GetWebhooksHandler)
+	v1Router.Path("/webhooks").Methods("POST").HandlerFunc(gameHandlers.CreateWebhookHandler)
 	v1Router.Path("/games/{game_id:" + uuidRegex + "}").Methods("GET").HandlerFunc(gameHandlers.GetGameHandler)
 	v1Router.Path("/games/{game_id:" + uuidRegex + "}").Methods("DELETE").HandlerFunc(gameHandlers.DeleteGameHandler)
 	v1Router.Path("/games/{game_id:" + uuidRegex + "}").Methods("PUT").HandlerFunc(gameHandlers.UpdateGameHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/moves").Methods("GET").HandlerFunc(gameHandlers.GetMovesHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/moves").Methods("POST").HandlerFunc(gameHandlers.MakeMoveHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/undo").Methods("POST").HandlerFunc(gameHandlers.UndoGameHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/join").Methods("POST").HandlerFunc(gameHandlers.JoinGameHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/ws").Methods("GET").HandlerFunc(gameHandlers.GameSocketHandler)
+	v1Router.Path("/games/{game_id:" + uuidRegex + "}/events").Methods("GET").HandlerFunc(gameHandlers.GameEventsHandler)
+	v1Router.Path("/webhooks/{webhook_id:" + uuidRegex + "}").Methods("GET").HandlerFunc(gameHandlers.GetWebhookHandler)
+	v1Router.Path("/webhooks/{webhook_id:" + uuidRegex + "}").Methods("DELETE").HandlerFunc(gameHandlers.DeleteWebhookHandler)
+	v1Router.Path("/webhooks/{webhook_id:" + uuidRegex + "}/deliveries").Methods("GET").HandlerFunc(gameHandlers.GetDeliveriesHandler)
+	v1Router.Path("/webhooks/{webhook_id:" + uuidRegex + "}/replay").Methods("POST").HandlerFunc(gameHandlers.ReplayDeliveriesHandler)
+	v1Router.Path("/webhooks/{webhook_id:" + uuidRegex + "}/deliveries/{delivery_id:" + uuidRegex + "}/replay").Methods("POST").HandlerFunc(gameHandlers.ReplayDeliveryHandler)
 }
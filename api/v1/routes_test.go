@@ -0,0 +1,96 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// Test_makeRoutes plays a game end to end through the routes against the in-memory repository
+func Test_makeRoutes(t *testing.T) {
+	router := mux.NewRouter()
+	makeRoutes(router, &Handlers{repo: repository.NewMemory(), undoLimit: 3})
+	do := func(method, target, body, ifMatch string, wantCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		if len(ifMatch) != 0 {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, r)
+		if rw.Code != wantCode {
+			t.Fatalf("%v %v code = %v, want %v: %v", method, target, rw.Code, wantCode, rw.Body.String())
+		}
+		return rw
+	}
+	decodeGame := func(rw *httptest.ResponseRecorder) *repository.Game {
+		t.Helper()
+		game := &repository.Game{}
+		if err := json.NewDecoder(rw.Body).Decode(game); err != nil {
+			t.Fatalf("decoding game: %v", err)
+		}
+		return game
+	}
+
+	rw := do("POST", "/api/v1/games", `{"board": "----X----", "strategy": "heuristic"}`, "", http.StatusCreated)
+	resp := newGameResponse{}
+	if err := json.NewDecoder(rw.Body).Decode(&resp); err != nil {
+		t.Fatalf("decoding new game response: %v", err)
+	}
+	location := resp.Location
+
+	rw = do("GET", location, "", "", http.StatusOK)
+	if got := rw.Header().Get("ETag"); got != `"1"` {
+		t.Errorf("ETag = %v, want %v", got, `"1"`)
+	}
+	if game := decodeGame(rw); game.Board != "O---X----" || game.Status != gameStatusRunning {
+		t.Errorf("new game = %v %v, want O---X---- %v", game.Board, game.Status, gameStatusRunning)
+	}
+
+	do("POST", location+"/moves", `{"position": 1}`, `"2"`, http.StatusPreconditionFailed)
+	if game := decodeGame(do("POST", location+"/moves", `{"position": 1}`, `"1"`, http.StatusOK)); game.Board != "OX--X--O-" {
+		t.Errorf("board after move = %v, want OX--X--O-", game.Board)
+	}
+	if game := decodeGame(do("POST", location+"/moves", `{"row": 1, "col": 0}`, "", http.StatusOK)); game.Board != "OX-XXO-O-" {
+		t.Errorf("board after move = %v, want OX-XXO-O-", game.Board)
+	}
+
+	moves := []repository.Move{}
+	if err := json.NewDecoder(do("GET", location+"/moves", "", "", http.StatusOK).Body).Decode(&moves); err != nil {
+		t.Fatalf("decoding moves: %v", err)
+	}
+	if len(moves) != 6 {
+		t.Errorf("moves = %v, want 6 moves", moves)
+	}
+
+	if game := decodeGame(do("POST", location+"/undo", "", "", http.StatusOK)); game.Board != "OX--X--O-" || game.Undos != 1 {
+		t.Errorf("game after undo = %v with %v undos, want OX--X--O- with 1 undo", game.Board, game.Undos)
+	}
+
+	page := repository.GamePage{}
+	if err := json.NewDecoder(do("GET", "/api/v1/games?status=RUNNING", "", "", http.StatusOK).Body).Decode(&page); err != nil {
+		t.Fatalf("decoding games: %v", err)
+	}
+	if page.Total != 1 || len(page.Games) != 1 || page.Games[0].Board != "OX--X--O-" {
+		t.Errorf("games = %+v, want the running game", page)
+	}
+
+	do("DELETE", location, "", "", http.StatusOK)
+	rw = do("GET", location, "", "", http.StatusNotFound)
+	if got := rw.Header().Get("Content-Type"); got != contentTypeProblem {
+		t.Errorf("Content-Type = %v, want %v", got, contentTypeProblem)
+	}
+	got := problem{}
+	if err := json.NewDecoder(rw.Body).Decode(&got); err != nil {
+		t.Fatalf("decoding problem: %v", err)
+	}
+	if got.Code != codeGameNotFound || got.Status != http.StatusNotFound || got.RequestID != rw.Header().Get(headerRequestID) {
+		t.Errorf("problem = %+v, want %v with the request id %v", got, codeGameNotFound, rw.Header().Get(headerRequestID))
+	}
+	do("DELETE", location, "", "", http.StatusNotFound)
+}
@@ -0,0 +1,248 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+const (
+	socketWriteWait      = 10 * time.Second        // time allowed to write a message
+	socketPongWait       = 60 * time.Second        // time allowed between two messages or pongs of the client
+	socketPingPeriod     = socketPongWait * 9 / 10 // pings are sent more often than the pongs are awaited
+	socketMaxMessageSize = 1024                    // moves are tiny
+	socketCloseWait      = time.Second             // time allowed to the client to close the socket once asked to
+)
+
+// Types of the messages sent over the game socket, besides the event types
+const (
+	socketGame  = "game"  // current state of the game, sent once connected
+	socketError = "error" // a move of the client was rejected
+	socketMove  = "move"  // a move of the client
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// eventMessage is a message sent over the game socket and the event streams
+type eventMessage struct {
+	Type    string           `json:"type"`
+	GameID  string           `json:"game_id,omitempty"`
+	Version int              `json:"version,omitempty"` // version of the game, as in its ETag
+	Game    *repository.Game `json:"game,omitempty"`
+	Error   *problem         `json:"error,omitempty"`
+}
+
+// socketRequest is a message received over the game socket, a move given like the body of MakeMoveHandler.
+// Version plays the part of If-Match: the move is rejected if the game is no longer at that version
+type socketRequest struct {
+	Type string `json:"type"`
+	moveRequest
+	Version *int `json:"version,omitempty"`
+}
+
+// gameSocket is the WebSocket of a client following a game
+type gameSocket struct {
+	conn        *websocket.Conn
+	mu          sync.Mutex // a single writer is allowed at a time
+	lastVersion int        // version of the last game sent, older events are not sent
+}
+
+// GameSocketHandler upgrades the request to a WebSocket which pushes every change of the game and accepts moves.
+// The current state of the game is sent first, so a client reconnecting catches up with the changes it missed.
+// Browsers cannot set headers on WebSockets, so the token of a player may also be given by the token query parameter
+func (h *Handlers) GameSocketHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	gameID := mux.Vars(r)["game_id"]
+	token := playerToken(r)
+	if token == "" {
+		token = r.URL.Query().Get("token")
+	}
+	c, err := h.newClient(r.Context(), gameID, token, "")
+	if err != nil {
+		logger.Error("unable to get player", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	// subscribe before reading the game, so that no change is missed in between
+	subscription := h.hub.Subscribe(gameID)
+	defer subscription.Close()
+	game, err := h.repo.GetGame(r.Context(), gameID)
+	if err != nil {
+		logger.Error("unable to get game", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if game == nil {
+		logger.Error("game not found", zap.String("gameid", gameID))
+		sendError(rw, gameNotFound())
+		return
+	}
+	if c.token != "" && c.player == nil && game.Mode == gameModeHuman {
+		logger.Error("invalid player token", zap.String("gameid", gameID))
+		sendError(rw, newAPIError(http.StatusForbidden, codeInvalidPlayerToken, "token is not one of a player of the game"))
+		return
+	}
+	// the server does not wait for the sockets on shutdown, so Close does before closing the repository
+	h.sockets.Add(1)
+	defer h.sockets.Done()
+	conn, err := upgrader.Upgrade(rw, r, nil)
+	if err != nil {
+		// the upgrader has already answered the request
+		logger.Error("websocket upgrade failed", zap.Error(err), zap.String("gameid", gameID))
+		return
+	}
+	socket := &gameSocket{conn: conn}
+	defer conn.Close()
+	if err := socket.send(eventMessage{Type: socketGame, GameID: gameID, Version: game.Version, Game: game}); err != nil {
+		logger.Info("websocket closed", zap.Error(err), zap.String("gameid", gameID))
+		return
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go socket.pushEvents(subscription, h.stopping, done)
+	h.readMoves(r, socket, gameID, c, rw.Header().Get(headerRequestID))
+}
+
+// readMoves plays the moves received over the socket until it is closed. Rejected moves are answered with an error message,
+// played moves reach the clients of the game, this one included, through the hub
+func (h *Handlers) readMoves(r *http.Request, socket *gameSocket, gameID string, c *client, requestID string) {
+	logger := logging.FromContext(r.Context())
+	socket.conn.SetReadLimit(socketMaxMessageSize)
+	socket.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	socket.conn.SetPongHandler(func(string) error {
+		return socket.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+	for {
+		_, message, err := socket.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Info("websocket closed", zap.Error(err), zap.String("gameid", gameID))
+			}
+			return
+		}
+		socket.conn.SetReadDeadline(time.Now().Add(socketPongWait))
+		game, err := h.socketMove(r, gameID, c, message)
+		if err == nil && game == nil {
+			err = gameNotFound()
+		}
+		if err != nil {
+			apiErr, ok := err.(*apiError)
+			if !ok {
+				logger.Error("game update failed", zap.Error(err), zap.String("gameid", gameID))
+				apiErr = repositoryError(err)
+			}
+			p := newProblem(apiErr, requestID)
+			if socket.send(eventMessage{Type: socketError, GameID: gameID, Error: &p}) != nil {
+				return
+			}
+			continue
+		}
+		h.played(game)
+	}
+}
+
+// socketMove decodes a move received over the socket and plays it
+func (h *Handlers) socketMove(r *http.Request, gameID string, c *client, message []byte) (*repository.Game, error) {
+	logger := logging.FromContext(r.Context())
+	request := socketRequest{}
+	if err := json.Unmarshal(message, &request); err != nil {
+		logger.Error("invalid websocket message", zap.Error(err), zap.String("gameid", gameID))
+		return nil, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid message").withField("body")
+	}
+	if request.Type != socketMove {
+		return nil, newAPIError(http.StatusBadRequest, codeInvalidRequest, "type must be move").withField("type")
+	}
+	position, apiErr := request.position()
+	if apiErr != nil {
+		logger.Error("invalid move", zap.String("gameid", gameID), zap.Error(apiErr))
+		return nil, apiErr
+	}
+	moveClient := *c
+	if request.Version != nil {
+		moveClient.ifMatch = etag(*request.Version)
+	}
+	return h.makeMove(r.Context(), gameID, position, &moveClient)
+}
+
+// pushEvents sends the events of the game over the socket and keeps it alive with pings until done is closed.
+// The socket is closed once the game is deleted, or when the subscription ends because the client fell behind
+// or the server is stopping, so that the client reconnects and catches up
+func (s *gameSocket) pushEvents(subscription *events.Subscription, stopping, done <-chan struct{}) {
+	ticker := time.NewTicker(socketPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-stopping:
+			s.goAway()
+			return
+		case <-ticker.C:
+			if s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)) != nil {
+				return
+			}
+		case event, ok := <-subscription.Events():
+			if !ok {
+				s.goAway()
+				return
+			}
+			if s.send(newEventMessage(event)) != nil {
+				s.conn.Close()
+				return
+			}
+			if event.Type == events.GameDeleted {
+				s.close(websocket.CloseNormalClosure)
+				return
+			}
+		}
+	}
+}
+
+// newEventMessage returns the message telling the clients about the event
+func newEventMessage(event events.Event) eventMessage {
+	message := eventMessage{Type: event.Type, GameID: event.GameID, Game: event.Game}
+	if event.Game != nil {
+		message.Version = event.Game.Version
+	}
+	return message
+}
+
+// send writes a message to the socket. A game older than the last game sent is skipped,
+// as the events of concurrent requests may be published out of order
+func (s *gameSocket) send(message eventMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if message.Game != nil {
+		if message.Version < s.lastVersion {
+			return nil
+		}
+		s.lastVersion = message.Version
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+	return s.conn.WriteJSON(message)
+}
+
+// close asks the client to close the socket
+func (s *gameSocket) close(code int) {
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, ""), time.Now().Add(socketWriteWait))
+}
+
+// goAway asks the client to reconnect later, and closes the socket if the client has not done so in time
+func (s *gameSocket) goAway() {
+	s.close(websocket.CloseGoingAway)
+	time.AfterFunc(socketCloseWait, func() {
+		s.conn.Close()
+	})
+}
@@ -0,0 +1,101 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// TestHandlers_GameSocketHandler plays moves over the socket of a game followed by a player and a spectator
+func TestHandlers_GameSocketHandler(t *testing.T) {
+	router := mux.NewRouter()
+	handlers := &Handlers{repo: repository.NewMemory(), hub: events.NewHub(), undoLimit: 3}
+	makeRoutes(router, handlers)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer handlers.hub.Close()
+
+	resp, err := http.Post(server.URL+"/api/v1/games", "application/json", strings.NewReader(`{"board": "----X----", "strategy": "heuristic"}`))
+	if err != nil {
+		t.Fatalf("creating game: %v", err)
+	}
+	created := newGameResponse{}
+	json.NewDecoder(resp.Body).Decode(&created)
+	resp.Body.Close()
+	socketURL := "ws" + strings.TrimPrefix(server.URL, "http") + created.Location + "/ws"
+
+	dial := func() *websocket.Conn {
+		t.Helper()
+		conn, _, err := websocket.DefaultDialer.Dial(socketURL, nil)
+		if err != nil {
+			t.Fatalf("dialing %v: %v", socketURL, err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		return conn
+	}
+	receive := func(conn *websocket.Conn, wantType string) eventMessage {
+		t.Helper()
+		message := eventMessage{}
+		if err := conn.ReadJSON(&message); err != nil {
+			t.Fatalf("reading %v message: %v", wantType, err)
+		}
+		if message.Type != wantType {
+			t.Fatalf("message = %+v, want type %v", message, wantType)
+		}
+		return message
+	}
+
+	player := dial()
+	defer player.Close()
+	spectator := dial()
+	defer spectator.Close()
+	for _, conn := range []*websocket.Conn{player, spectator} {
+		if message := receive(conn, socketGame); message.Version != 1 || message.Game.Board != "O---X----" {
+			t.Errorf("state = %+v, want the game at version 1", message)
+		}
+	}
+
+	player.WriteJSON(map[string]interface{}{"type": "move", "position": 1, "version": 2})
+	if message := receive(player, socketError); message.Error.Code != codeGameModified {
+		t.Errorf("stale move error = %+v, want %v", message.Error, codeGameModified)
+	}
+	player.WriteJSON(map[string]interface{}{"type": "move", "position": 0})
+	if message := receive(player, socketError); message.Error.Code != codeCellOccupied || *message.Error.Position != 0 {
+		t.Errorf("occupied cell error = %+v, want %v at 0", message.Error, codeCellOccupied)
+	}
+	player.WriteMessage(websocket.TextMessage, []byte("{"))
+	if message := receive(player, socketError); message.Error.Code != codeInvalidRequest {
+		t.Errorf("invalid message error = %+v, want %v", message.Error, codeInvalidRequest)
+	}
+
+	player.WriteJSON(map[string]interface{}{"type": "move", "row": 0, "col": 1, "version": 1})
+	for _, conn := range []*websocket.Conn{player, spectator} {
+		if message := receive(conn, events.MoveMade); message.Version != 2 || message.Game.Board != "OX--X--O-" {
+			t.Errorf("move = %+v, want OX--X--O- at version 2", message)
+		}
+	}
+
+	req, _ := http.NewRequest("DELETE", server.URL+created.Location, nil)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("deleting game: %v %v", resp, err)
+	}
+	for _, conn := range []*websocket.Conn{player, spectator} {
+		receive(conn, events.GameDeleted)
+		if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+			t.Errorf("read after delete error = %v, want a normal closure", err)
+		}
+	}
+
+	if _, resp, err := websocket.DefaultDialer.Dial(socketURL, nil); err == nil || resp.StatusCode != http.StatusNotFound {
+		t.Errorf("dialing deleted game = %v, want %v", err, http.StatusNotFound)
+	}
+}
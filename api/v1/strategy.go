@@ -0,0 +1,117 @@
+package v1
+
+import (
+	"math/rand"
+	"strings"
+	"sync"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+)
+
+// Strategy decides the moves of the computer player
+type Strategy interface {
+	// Move returns the position on the board where mark should be played.
+	// It is only called when there is at least one blank position on the board.
+	// rand is seeded from the game so that strategies using it are reproducible
+	Move(board string, mark string, rand *rand.Rand) int
+}
+
+var (
+	strategiesMu sync.RWMutex
+	strategies   = make(map[string]Strategy)
+)
+
+func init() {
+	RegisterStrategy(strategyRandom, randomStrategy{})
+	RegisterStrategy(strategyHeuristic, heuristicStrategy{})
+	RegisterStrategy(strategyMinimax, minimaxStrategy{})
+	// the defaults of the configuration are checked when it is loaded, before the handlers are created
+	config.SetGameChoices(func(name string) bool {
+		_, ok := getStrategy(name)
+		return ok
+	}, func(name string) bool {
+		_, ok := difficultyRates[name]
+		return ok
+	})
+}
+
+// RegisterStrategy makes a strategy available by the provided name.
+// The name can then be passed as strategy while creating a new game.
+// If RegisterStrategy is called twice with the same name or if strategy is nil, it panics
+func RegisterStrategy(name string, strategy Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	if strategy == nil {
+		panic("tictactoe: RegisterStrategy strategy is nil")
+	}
+	if _, dup := strategies[name]; dup {
+		panic("tictactoe: RegisterStrategy called twice for strategy " + name)
+	}
+	strategies[name] = strategy
+}
+
+// getStrategy returns the strategy registered by name
+func getStrategy(name string) (Strategy, bool) {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+	strategy, ok := strategies[name]
+	return strategy, ok
+}
+
+// unregisterStrategy removes the strategy registered by name, so that tests do not leave their strategies behind
+func unregisterStrategy(name string) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+	delete(strategies, name)
+}
+
+// randomStrategy randomly selects a blank position. It does not intentionally try to win
+type randomStrategy struct{}
+
+func (randomStrategy) Move(board string, mark string, rand *rand.Rand) int {
+	validPositions := findBlankPositions(strings.Split(board, ""))
+	return validPositions[rand.Intn(len(validPositions))]
+}
+
+// heuristicStrategy plays like a beginner following simple rules:
+// win if possible, else block the opponent, else prefer the center, then the corners, then the sides
+type heuristicStrategy struct{}
+
+// heuristicPreference is the order in which the heuristic strategy takes positions when there is nothing to win or block
+var heuristicPreference = []int{4, 0, 2, 6, 8, 1, 3, 5, 7}
+
+func (heuristicStrategy) Move(board string, mark string, rand *rand.Rand) int {
+	moves := strings.Split(board, "")
+	if position := findWinningPosition(moves, mark); position != -1 {
+		return position
+	}
+	if position := findWinningPosition(moves, findOpponentMark(mark)); position != -1 {
+		return position
+	}
+	for _, position := range heuristicPreference {
+		if moves[position] == fMark {
+			return position
+		}
+	}
+	return -1
+}
+
+// findWinningPosition returns a position which completes a line for mark or -1 if there is none
+func findWinningPosition(moves []string, mark string) int {
+	for _, position := range findBlankPositions(moves) {
+		moves[position] = mark
+		winner := findWinner(moves)
+		moves[position] = fMark
+		if winner == mark {
+			return position
+		}
+	}
+	return -1
+}
+
+// minimaxStrategy plays perfectly and never loses
+type minimaxStrategy struct{}
+
+func (minimaxStrategy) Move(board string, mark string, rand *rand.Rand) int {
+	return bestMove(strings.Split(board, ""), mark)
+}
@@ -0,0 +1,104 @@
+package v1
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+)
+
+func Test_heuristicStrategy_Move(t *testing.T) {
+	tests := []struct {
+		name  string
+		board string
+		mark  string
+		want  int
+	}{
+		{
+			name:  "Win",
+			board: "OO-XX----",
+			mark:  xMark,
+			want:  5,
+		},
+		{
+			name:  "Block",
+			board: "X--X---O-",
+			mark:  oMark,
+			want:  6,
+		},
+		{
+			name:  "Center",
+			board: "X--------",
+			mark:  oMark,
+			want:  4,
+		},
+		{
+			name:  "Corner",
+			board: "----X----",
+			mark:  oMark,
+			want:  0,
+		},
+		{
+			name:  "Side",
+			board: "XOX-X-OXO",
+			mark:  oMark,
+			want:  3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := (heuristicStrategy{}).Move(tt.board, tt.mark, nil); got != tt.want {
+				t.Errorf("heuristicStrategy.Move() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_randomStrategy_Move(t *testing.T) {
+	board := "XOXOX-OXO"
+	for i := int64(0); i < 10; i++ {
+		if got := (randomStrategy{}).Move(board, xMark, rand.New(rand.NewSource(i))); got != 5 {
+			t.Fatalf("randomStrategy.Move() = %v, want %v", got, 5)
+		}
+	}
+}
+
+type firstBlankStrategy struct{}
+
+func (firstBlankStrategy) Move(board string, mark string, rand *rand.Rand) int {
+	return strings.Index(board, fMark)
+}
+
+func TestRegisterStrategy(t *testing.T) {
+	RegisterStrategy("first_blank", firstBlankStrategy{})
+	defer unregisterStrategy("first_blank")
+
+	g := &Game{Strategy: "first_blank"}
+	if !g.validateStrategy("") {
+		t.Fatalf("registered strategy not accepted")
+	}
+	cfg := config.Default()
+	cfg.DB.SQLConn = memoryConn
+	cfg.Game.DefaultStrategy = "first_blank"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("registered strategy not accepted as default : %v", err)
+	}
+	g.Board = "X--------"
+	g.play(oMark, g.Strategy, difficultyPerfect, 1)
+	if g.Board != "XO-------" {
+		t.Errorf("registered strategy not played : got %v want %v", g.Board, "XO-------")
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("RegisterStrategy did not panic on duplicate name")
+			}
+		}()
+		RegisterStrategy("first_blank", firstBlankStrategy{})
+	}()
+	if _, ok := getStrategy("first_blank"); !ok {
+		t.Errorf("duplicate registration removed the registered strategy")
+	}
+}
@@ -0,0 +1,141 @@
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+)
+
+// streamKeepAlive is the interval of the comments keeping idle streams open through proxies
+const streamKeepAlive = 15 * time.Second
+
+// streamReset is the type of the event telling the client that some of the events it asked for are no longer available
+const streamReset = "reset"
+
+// EventsHandler streams the events of all the games as Server-Sent Events
+func (h *Handlers) EventsHandler(rw http.ResponseWriter, r *http.Request) {
+	h.streamEvents(rw, r, "")
+}
+
+// GameEventsHandler streams the events of a game as Server-Sent Events. The stream ends once the game is deleted
+func (h *Handlers) GameEventsHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	gameID := mux.Vars(r)["game_id"]
+	game, err := h.repo.GetGame(r.Context(), gameID)
+	if err != nil {
+		logger.Error("unable to get game", zap.Error(err), zap.String("gameid", gameID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if game == nil {
+		logger.Error("game not found", zap.String("gameid", gameID))
+		sendError(rw, gameNotFound())
+		return
+	}
+	h.streamEvents(rw, r, gameID)
+}
+
+// streamEvents streams the events of the game, or of all the games if gameID is empty, until the client goes away.
+// A client resuming with Last-Event-ID first gets the events it missed, preceded by a reset event if some are no longer kept.
+// The stream ends when the client falls behind or the server stops, the client then resumes with the id of the last event
+func (h *Handlers) streamEvents(rw http.ResponseWriter, r *http.Request, gameID string) {
+	logger := logging.FromContext(r.Context())
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		logger.Error("response cannot be streamed")
+		sendError(rw, newAPIError(http.StatusInternalServerError, codeInternal, msgInternalServerError))
+		return
+	}
+	lastID, resume, apiErr := lastEventID(r)
+	if apiErr != nil {
+		logger.Error("invalid last event id", zap.Error(apiErr))
+		sendError(rw, apiErr)
+		return
+	}
+	var subscription *events.Subscription
+	var missed []events.Event
+	complete := true
+	if resume {
+		subscription, missed, complete = h.hub.SubscribeAfter(gameID, lastID)
+	} else {
+		subscription = h.hub.Subscribe(gameID)
+	}
+	defer subscription.Close()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	// proxies must not buffer the stream
+	rw.Header().Set("X-Accel-Buffering", "no")
+	rw.WriteHeader(http.StatusOK)
+	if !complete {
+		logger.Info("events missed", zap.Uint64("last_event_id", lastID))
+		fmt.Fprintf(rw, "event: %s\ndata: {\"type\":%q}\n\n", streamReset, streamReset)
+	}
+	for _, event := range missed {
+		if !writeEvent(rw, event, gameID) {
+			flusher.Flush()
+			return
+		}
+	}
+	flusher.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-h.stopping:
+			return
+		case <-ticker.C:
+			fmt.Fprint(rw, ": keep-alive\n\n")
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return
+			}
+			if !writeEvent(rw, event, gameID) {
+				flusher.Flush()
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
+
+// writeEvent writes the event to the stream. Returns false once the stream has to end,
+// because the client went away or the game of the stream was deleted
+func writeEvent(rw http.ResponseWriter, event events.Event, gameID string) bool {
+	data, err := json.Marshal(newEventMessage(event))
+	if err != nil {
+		return false
+	}
+	if _, err := fmt.Fprintf(rw, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data); err != nil {
+		return false
+	}
+	return gameID == "" || event.Type != events.GameDeleted
+}
+
+// lastEventID returns the id of the last event received by a client resuming a stream, sent in the Last-Event-ID header,
+// or in the last_event_id query parameter by clients which cannot set headers. resume is false if there is none
+func lastEventID(r *http.Request) (id uint64, resume bool, apiErr *apiError) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, newAPIError(http.StatusBadRequest, codeInvalidParameter, "invalid last event id").withField("last_event_id")
+	}
+	return id, true, nil
+}
@@ -0,0 +1,133 @@
+package v1
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// streamedEvent is an event read from a stream
+type streamedEvent struct {
+	id      string
+	event   string
+	message eventMessage
+}
+
+// readEvents reads the events of a stream until it ends
+func readEvents(t *testing.T, resp *http.Response) <-chan streamedEvent {
+	received := make(chan streamedEvent)
+	go func() {
+		defer close(received)
+		defer resp.Body.Close()
+		scanner := bufio.NewScanner(resp.Body)
+		current := streamedEvent{}
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+				received <- current
+				current = streamedEvent{}
+			case strings.HasPrefix(line, "id: "):
+				current.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				current.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &current.message); err != nil {
+					t.Errorf("decoding event data %v: %v", line, err)
+				}
+			}
+		}
+	}()
+	return received
+}
+
+// TestHandlers_streams follows all the games and a single game while games are created, played and deleted
+func TestHandlers_streams(t *testing.T) {
+	router := mux.NewRouter()
+	handlers := &Handlers{repo: repository.NewMemory(), hub: events.NewHub(), undoLimit: 3}
+	makeRoutes(router, handlers)
+	server := httptest.NewServer(router)
+	defer server.Close()
+	defer handlers.hub.Close()
+
+	follow := func(path string, lastEventID string, wantCode int) <-chan streamedEvent {
+		t.Helper()
+		req, _ := http.NewRequest("GET", server.URL+path, nil)
+		if len(lastEventID) != 0 {
+			req.Header.Set("Last-Event-ID", lastEventID)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET %v: %v", path, err)
+		}
+		if resp.StatusCode != wantCode {
+			resp.Body.Close()
+			t.Fatalf("GET %v code = %v, want %v", path, resp.StatusCode, wantCode)
+		}
+		if wantCode != http.StatusOK {
+			resp.Body.Close()
+			return nil
+		}
+		if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+			t.Errorf("Content-Type = %v, want text/event-stream", got)
+		}
+		return readEvents(t, resp)
+	}
+	do := func(method, path, body string) string {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%v %v: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		created := newGameResponse{}
+		json.NewDecoder(resp.Body).Decode(&created)
+		return created.Location
+	}
+	expect := func(stream <-chan streamedEvent, wantEvents ...string) []streamedEvent {
+		t.Helper()
+		var got []streamedEvent
+		for _, want := range wantEvents {
+			event, ok := <-stream
+			if !ok || event.event != want || (want != streamReset && (event.id == "" || event.message.Type != want)) {
+				t.Fatalf("event = %+v, %v, want %v", event, ok, want)
+			}
+			got = append(got, event)
+		}
+		return got
+	}
+
+	all := follow("/api/v1/events", "", http.StatusOK)
+	location := do("POST", "/api/v1/games", `{"board": "---------", "strategy": "heuristic"}`)
+	created := expect(all, events.GameCreated)[0]
+	if created.message.Game == nil || created.message.Version != 1 || created.message.GameID != created.message.Game.ID {
+		t.Errorf("game_created = %+v, want the new game at version 1", created.message)
+	}
+
+	game := follow(location+"/events", "", http.StatusOK)
+	do("POST", location+"/moves", `{"position": 1}`)
+	expect(game, events.MoveMade)
+	do("DELETE", location, "")
+	expect(game, events.GameDeleted)
+	if event, ok := <-game; ok {
+		t.Errorf("game stream event after game_deleted = %+v, want the stream to end", event)
+	}
+	expect(all, events.MoveMade, events.GameDeleted)
+
+	resumed := follow("/api/v1/events", created.id, http.StatusOK)
+	expect(resumed, events.MoveMade, events.GameDeleted)
+	reset := follow("/api/v1/events", "1", http.StatusOK)
+	expect(reset, streamReset, events.GameCreated, events.MoveMade, events.GameDeleted)
+
+	follow(location+"/events", "", http.StatusNotFound)
+	follow("/api/v1/events", "latest", http.StatusBadRequest)
+}
@@ -1,17 +1,73 @@
 package v1
 
-import "github.com/sunilkumarmohanty/tictactoe/repository"
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
 
 // IRepository is used as an interface for storing record in a repository
-// Using it also makes it easier to write Unit test cases
+// Using it also makes it easier to write Unit test cases.
+// Every method takes the context of the request, so that calls are abandoned when the client goes away
 type IRepository interface {
-	GetGames() ([]repository.Game, error)
-	GetGame(string) (*repository.Game, error)
-	NewGame(string, string) (string, error)
-	UpdateGame(*repository.Game) (int64, error)
-	DeleteGame(string) (int64, error)
+	GetGames(context.Context, *repository.GameFilter) (*repository.GamePage, error)
+	CountGames(context.Context, *repository.GameFilter) (int, error)
+	GetGame(context.Context, string) (*repository.Game, error)
+	NewGame(context.Context, *repository.Game, []repository.Move, []repository.Player) (string, error)
+	PlayMove(context.Context, string, func(*repository.Game) ([]repository.Move, error)) (*repository.Game, error)
+	DeleteGame(context.Context, string) (int64, error)
+	GetMoves(context.Context, string) ([]repository.Move, error)
+	UndoMoves(context.Context, *repository.Game, int) (int64, error)
+	JoinGame(context.Context, string, func(*repository.Game, []repository.Player) (*repository.Player, error)) (*repository.Player, error)
+	GetPlayer(context.Context, string, string) (*repository.Player, error)
+	NewWebhook(context.Context, *repository.Webhook) (string, error)
+	GetWebhooks(context.Context) ([]repository.Webhook, error)
+	GetWebhook(context.Context, string) (*repository.Webhook, error)
+	DeleteWebhook(context.Context, string) (int64, error)
+	NewDeliveries(context.Context, []repository.Delivery) error
+	GetDeliveries(context.Context, string, string, int) ([]repository.Delivery, error)
+	GetDelivery(context.Context, string, string) (*repository.Delivery, error)
+	ReplayDeliveries(context.Context, string, string) (int64, error)
+	ClaimDeliveries(context.Context, int, time.Time) ([]repository.Delivery, error)
+	UpdateDelivery(context.Context, *repository.Delivery) error
+	Close() error
 }
 
+// newGameResponse is sent once a game is created or joined. Players of games between humans get their mark and token
 type newGameResponse struct {
 	Location string `json:"location,omitempty"`
+	Mark     string `json:"mark,omitempty"`
+	Token    string `json:"token,omitempty"`
+}
+
+// moveRequest is a single move made by the opponent, given either by position or by row and col
+type moveRequest struct {
+	Position *int `json:"position,omitempty"`
+	Row      *int `json:"row,omitempty"`
+	Col      *int `json:"col,omitempty"`
+}
+
+// position returns the board position of the move or the error if the move is invalid
+func (m *moveRequest) position() (int, *apiError) {
+	if m.Position != nil {
+		if m.Row != nil || m.Col != nil {
+			return 0, newAPIError(http.StatusBadRequest, codeInvalidPosition, "either position or row and col required").withField("position")
+		}
+		if *m.Position < 0 || *m.Position > 8 {
+			return 0, newAPIError(http.StatusBadRequest, codeInvalidPosition, "position out of range").withField("position")
+		}
+		return *m.Position, nil
+	}
+	if m.Row == nil || m.Col == nil {
+		return 0, newAPIError(http.StatusBadRequest, codeInvalidPosition, "either position or row and col required").withField("position")
+	}
+	if *m.Row < 0 || *m.Row > 2 {
+		return 0, newAPIError(http.StatusBadRequest, codeInvalidPosition, "row or col out of range").withField("row")
+	}
+	if *m.Col < 0 || *m.Col > 2 {
+		return 0, newAPIError(http.StatusBadRequest, codeInvalidPosition, "row or col out of range").withField("col")
+	}
+	return *m.Row*3 + *m.Col, nil
 }
@@ -0,0 +1,241 @@
+package v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+	"github.com/sunilkumarmohanty/tictactoe/webhooks"
+)
+
+// webhookRequest is the body registering a webhook. It subscribes to all the event types if none is given
+type webhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+// newWebhookResponse is sent once a webhook is registered, along with the secret signing its deliveries which is never sent again
+type newWebhookResponse struct {
+	*repository.Webhook
+	Secret string `json:"secret"`
+}
+
+// replayResponse tells how many failed deliveries were replayed
+type replayResponse struct {
+	Replayed int64 `json:"replayed"`
+}
+
+// CreateWebhookHandler registers a webhook receiving the events of the games of the given types
+func (h *Handlers) CreateWebhookHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	req := &webhookRequest{}
+	err := json.NewDecoder(r.Body).Decode(req)
+	if err != nil {
+		logger.Error("invalid body while creating webhook", zap.Error(err))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidRequest, "invalid request body").withField("body"))
+		return
+	}
+	if u, err := url.Parse(req.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		logger.Error("invalid webhook url", zap.String("url", req.URL))
+		sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidURL, "url must be an absolute http or https URL").withField("url"))
+		return
+	}
+	if len(req.Events) == 0 {
+		req.Events = webhooks.EventTypes
+	}
+	for _, eventType := range req.Events {
+		if !validEventType(eventType) {
+			logger.Error("invalid webhook event", zap.String("event", eventType))
+			sendError(rw, newAPIError(http.StatusBadRequest, codeInvalidEvent, "unknown event type "+eventType).withField("events"))
+			return
+		}
+	}
+	secret, err := newToken()
+	if err != nil {
+		logger.Error("unable to create webhook secret", zap.Error(err))
+		sendError(rw, newAPIError(http.StatusInternalServerError, codeInternal, msgInternalServerError))
+		return
+	}
+	webhook := &repository.Webhook{URL: req.URL, Events: req.Events, Secret: secret}
+	webhookID, err := h.repo.NewWebhook(r.Context(), webhook)
+	if err != nil {
+		logger.Error("webhook creation failed", zap.Error(err))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	logger.Info("webhook created", zap.String("webhook_id", webhookID), zap.String("url", webhook.URL))
+	rw.WriteHeader(http.StatusCreated)
+	json.NewEncoder(rw).Encode(newWebhookResponse{Webhook: webhook, Secret: secret})
+}
+
+// GetWebhooksHandler returns all the webhooks in the order they were registered
+func (h *Handlers) GetWebhooksHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	registered, err := h.repo.GetWebhooks(r.Context())
+	if err != nil {
+		logger.Error("unable to get webhooks", zap.Error(err))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	json.NewEncoder(rw).Encode(registered)
+}
+
+// GetWebhookHandler returns a single webhook
+func (h *Handlers) GetWebhookHandler(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	webhook, ok := h.requestWebhook(rw, r)
+	if !ok {
+		return
+	}
+	json.NewEncoder(rw).Encode(webhook)
+}
+
+// DeleteWebhookHandler deletes a webhook along with its deliveries
+func (h *Handlers) DeleteWebhookHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	webhookID := mux.Vars(r)["webhook_id"]
+	rowsAffected, err := h.repo.DeleteWebhook(r.Context(), webhookID)
+	if err != nil {
+		logger.Error("webhook deletion failed", zap.Error(err))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if rowsAffected == 0 {
+		logger.Error("webhook not found", zap.String("webhookid", webhookID))
+		sendError(rw, webhookNotFound())
+		return
+	}
+}
+
+// GetDeliveriesHandler returns the last deliveries of a webhook, newest first. The query parameters limit the number of
+// deliveries, 1-100 (default 20), and select the deliveries with the status if any
+func (h *Handlers) GetDeliveriesHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	query := r.URL.Query()
+	limit := defaultPageSize
+	if value := query.Get("limit"); len(value) != 0 {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageSize {
+			sendError(rw, invalidParameter("limit"))
+			return
+		}
+	}
+	status := query.Get("status")
+	switch status {
+	case "", repository.DeliveryPending, repository.DeliveryDelivered, repository.DeliveryFailed:
+	default:
+		sendError(rw, invalidParameter("status"))
+		return
+	}
+	webhook, ok := h.requestWebhook(rw, r)
+	if !ok {
+		return
+	}
+	deliveries, err := h.repo.GetDeliveries(r.Context(), webhook.ID, status, limit)
+	if err != nil {
+		logger.Error("unable to get deliveries", zap.Error(err), zap.String("webhookid", webhook.ID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	json.NewEncoder(rw).Encode(deliveries)
+}
+
+// ReplayDeliveryHandler attempts a failed delivery of a webhook again, with all its attempts ahead of it
+func (h *Handlers) ReplayDeliveryHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	params := mux.Vars(r)
+	delivery, err := h.repo.GetDelivery(r.Context(), params["webhook_id"], params["delivery_id"])
+	if err != nil {
+		logger.Error("unable to get delivery", zap.Error(err), zap.String("deliveryid", params["delivery_id"]))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	if delivery == nil {
+		logger.Error("delivery not found", zap.String("deliveryid", params["delivery_id"]))
+		sendError(rw, newAPIError(http.StatusNotFound, codeDeliveryNotFound, "delivery not found"))
+		return
+	}
+	replayed, err := h.repo.ReplayDeliveries(r.Context(), delivery.WebhookID, delivery.ID)
+	if err != nil {
+		logger.Error("delivery replay failed", zap.Error(err), zap.String("deliveryid", delivery.ID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	// replayed is 0 when the delivery has not failed, or was replayed by another request in the meantime
+	if replayed == 0 {
+		logger.Error("delivery not failed", zap.String("deliveryid", delivery.ID), zap.String("status", delivery.Status))
+		sendError(rw, newAPIError(http.StatusConflict, codeDeliveryNotFailed, "only failed deliveries can be replayed"))
+		return
+	}
+	h.dispatcher.Wake()
+	now := time.Now().UTC()
+	delivery.Status = repository.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = &now
+	json.NewEncoder(rw).Encode(delivery)
+}
+
+// ReplayDeliveriesHandler attempts all the failed deliveries of a webhook again, with all their attempts ahead of them
+func (h *Handlers) ReplayDeliveriesHandler(rw http.ResponseWriter, r *http.Request) {
+	logger := logging.FromContext(r.Context())
+	rw.Header().Set("Content-Type", "application/json")
+	webhook, ok := h.requestWebhook(rw, r)
+	if !ok {
+		return
+	}
+	replayed, err := h.repo.ReplayDeliveries(r.Context(), webhook.ID, "")
+	if err != nil {
+		logger.Error("deliveries replay failed", zap.Error(err), zap.String("webhookid", webhook.ID))
+		sendError(rw, repositoryError(err))
+		return
+	}
+	logger.Info("deliveries replayed", zap.String("webhook_id", webhook.ID), zap.Int64("replayed", replayed))
+	h.dispatcher.Wake()
+	json.NewEncoder(rw).Encode(replayResponse{Replayed: replayed})
+}
+
+// requestWebhook gets the webhook of the request. The error is sent and ok is false if it cannot be found
+func (h *Handlers) requestWebhook(rw http.ResponseWriter, r *http.Request) (webhook *repository.Webhook, ok bool) {
+	logger := logging.FromContext(r.Context())
+	webhookID := mux.Vars(r)["webhook_id"]
+	webhook, err := h.repo.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		logger.Error("unable to get webhook", zap.Error(err), zap.String("webhookid", webhookID))
+		sendError(rw, repositoryError(err))
+		return nil, false
+	}
+	if webhook == nil {
+		logger.Error("webhook not found", zap.String("webhookid", webhookID))
+		sendError(rw, webhookNotFound())
+		return nil, false
+	}
+	return webhook, true
+}
+
+// validEventType reports if webhooks can subscribe to the events of the type
+func validEventType(eventType string) bool {
+	for _, valid := range webhooks.EventTypes {
+		if eventType == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// webhookNotFound is the error sent when the webhook of the request does not exist
+func webhookNotFound() *apiError {
+	return newAPIError(http.StatusNotFound, codeWebhookNotFound, "webhook not found")
+}
@@ -0,0 +1,138 @@
+package v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+	"github.com/sunilkumarmohanty/tictactoe/webhooks"
+)
+
+// Test_webhooks registers a webhook through the routes, has its deliveries fail, replays them and deletes it
+func Test_webhooks(t *testing.T) {
+	var mu sync.Mutex
+	failing := true
+	var received []*http.Request
+	var bodies [][]byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, r)
+		bodies = append(bodies, body)
+		if failing {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer receiver.Close()
+
+	repo := repository.NewMemory()
+	hub := events.NewHub()
+	handlers := &Handlers{
+		repo:       repo,
+		hub:        hub,
+		dispatcher: webhooks.NewDispatcher(repo, hub, &config.Webhooks{Timeout: time.Second, MaxAttempts: 2, Backoff: 10 * time.Millisecond}),
+		undoLimit:  3,
+	}
+	defer handlers.Close()
+	router := mux.NewRouter()
+	makeRoutes(router, handlers)
+	do := func(method, target, body string, wantCode int, wantErrCode string) *httptest.ResponseRecorder {
+		t.Helper()
+		rw := httptest.NewRecorder()
+		router.ServeHTTP(rw, httptest.NewRequest(method, target, strings.NewReader(body)))
+		if rw.Code != wantCode {
+			t.Fatalf("%v %v code = %v, want %v: %v", method, target, rw.Code, wantCode, rw.Body.String())
+		}
+		if len(wantErrCode) != 0 {
+			got := problem{}
+			if err := json.NewDecoder(rw.Body).Decode(&got); err != nil || got.Code != wantErrCode {
+				t.Fatalf("%v %v problem = %+v, %v, want %v", method, target, got, err, wantErrCode)
+			}
+		}
+		return rw
+	}
+	waitFor := func(target string, count int) []repository.Delivery {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			deliveries := []repository.Delivery{}
+			json.NewDecoder(do("GET", target, "", http.StatusOK, "").Body).Decode(&deliveries)
+			if len(deliveries) == count {
+				return deliveries
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("GET %v = %+v, want %v deliveries", target, deliveries, count)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	do("POST", "/api/v1/webhooks", `{"url": "ftp://example.com"}`, http.StatusBadRequest, codeInvalidURL)
+	do("POST", "/api/v1/webhooks", `{"url": "/hooks"}`, http.StatusBadRequest, codeInvalidURL)
+	do("POST", "/api/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["game_won"]}`, http.StatusBadRequest, codeInvalidEvent)
+
+	created := newWebhookResponse{}
+	json.NewDecoder(do("POST", "/api/v1/webhooks", `{"url": "`+receiver.URL+`", "events": ["game_created"]}`, http.StatusCreated, "").Body).Decode(&created)
+	if created.Webhook == nil || len(created.Secret) != 2*tokenLength || created.URL != receiver.URL {
+		t.Fatalf("created webhook = %+v, want the webhook with a secret", created)
+	}
+	hook := "/api/v1/webhooks/" + created.ID
+	if body := do("GET", hook, "", http.StatusOK, "").Body.String(); strings.Contains(body, created.Secret) {
+		t.Errorf("GET %v = %v, want the secret left out", hook, body)
+	}
+	registered := []repository.Webhook{}
+	if json.NewDecoder(do("GET", "/api/v1/webhooks", "", http.StatusOK, "").Body).Decode(&registered); len(registered) != 1 || registered[0].ID != created.ID {
+		t.Errorf("webhooks = %+v, want %v", registered, created.ID)
+	}
+
+	do("POST", "/api/v1/games", `{"board": "---------", "strategy": "heuristic"}`, http.StatusCreated, "")
+	failed := waitFor(hook+"/deliveries?status=failed", 1)[0]
+	if failed.EventType != events.GameCreated || failed.Attempts != 2 || failed.ResponseCode != http.StatusInternalServerError {
+		t.Errorf("failed delivery = %+v, want game_created failing twice with 500", failed)
+	}
+	do("GET", hook+"/deliveries?limit=0", "", http.StatusBadRequest, codeInvalidParameter)
+	do("GET", hook+"/deliveries?status=lost", "", http.StatusBadRequest, codeInvalidParameter)
+
+	mu.Lock()
+	failing = false
+	mu.Unlock()
+	replay := hook + "/deliveries/" + failed.ID + "/replay"
+	do("POST", replay, "", http.StatusOK, "")
+	if delivered := waitFor(hook+"/deliveries?status=delivered", 1)[0]; delivered.ID != failed.ID || delivered.Attempts != 1 {
+		t.Errorf("replayed delivery = %+v, want %v delivered on its first attempt", delivered, failed.ID)
+	}
+	do("POST", replay, "", http.StatusConflict, codeDeliveryNotFailed)
+	do("POST", hook+"/deliveries/00000000-0000-4000-8000-000000000000/replay", "", http.StatusNotFound, codeDeliveryNotFound)
+	if body := do("POST", hook+"/replay", "", http.StatusOK, "").Body.String(); !strings.Contains(body, `"replayed":0`) {
+		t.Errorf("replay of no failed deliveries = %v, want 0 replayed", body)
+	}
+
+	mu.Lock()
+	if len(received) != 3 {
+		t.Errorf("requests = %v, want 3", len(received))
+	}
+	for indx, r := range received {
+		if got, want := r.Header.Get(webhooks.HeaderSignature), webhooks.Sign(created.Secret, bodies[indx]); got != want {
+			t.Errorf("signature = %v, want %v", got, want)
+		}
+		if got := r.Header.Get(webhooks.HeaderDelivery); got != failed.ID {
+			t.Errorf("delivery header = %v, want %v", got, failed.ID)
+		}
+	}
+	mu.Unlock()
+
+	do("DELETE", hook, "", http.StatusOK, "")
+	do("DELETE", hook, "", http.StatusNotFound, codeWebhookNotFound)
+	do("GET", hook+"/deliveries", "", http.StatusNotFound, codeWebhookNotFound)
+}
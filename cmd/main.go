@@ -1,10 +1,34 @@
 package main
 
 import (
+	"flag"
+	"log"
+	"os"
+
 	"github.com/sunilkumarmohanty/tictactoe/api"
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
 )
 
 func main() {
+	cfg, args, err := config.Load(os.Args[0], os.Args[1:])
+	if err == flag.ErrHelp {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	err = logging.Configure(cfg.LogLevel, cfg.LogFormat)
+	if err != nil {
+		log.Fatal(err)
+	}
 
-	api.Run()
+	if len(args) > 0 && args[0] == "migrate" {
+		err = runMigrate(cfg, args[1:])
+	} else {
+		err = api.Run(cfg)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// migrateUsage describes the migrate subcommand
+const migrateUsage = `usage: app [flags] migrate <command>
+
+Commands:
+  up          apply all the pending migrations
+  down [N]    revert the last N applied migrations (default 1)
+  goto V      apply or revert migrations until V is the last applied version
+  status      list the migrations and whether they are applied
+
+The database is configured like for running the service, e.g. with -sql-conn or SQL_CONN`
+
+// runMigrate runs the migrate subcommand with its arguments
+func runMigrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+	// validate the arguments before connecting to the database
+	var steps int
+	var version uint64
+	var err error
+	switch args[0] {
+	case "up", "status":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+	case "down":
+		steps = 1
+		if len(args) == 2 {
+			steps, err = strconv.Atoi(args[1])
+		}
+		if len(args) > 2 || err != nil || steps < 1 {
+			return errors.New(migrateUsage)
+		}
+	case "goto":
+		if len(args) == 2 {
+			version, err = strconv.ParseUint(args[1], 10, 64)
+		}
+		if len(args) != 2 || err != nil {
+			return errors.New(migrateUsage)
+		}
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := repository.NewMigrator(&cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer migrator.Close()
+	switch args[0] {
+	case "up":
+		err = migrator.Up()
+	case "down":
+		err = migrator.Down(steps)
+	case "goto":
+		err = migrator.Goto(uint(version))
+	}
+	if err != nil {
+		return err
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		state := "pending"
+		if status.Dirty {
+			state = "dirty"
+		} else if status.Applied {
+			state = "applied"
+		}
+		fmt.Printf("%d %-12s %s\n", status.Version, status.Name, state)
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// Package config loads the configuration of the service from command-line flags, an optional YAML file and environment variables
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the configuration of the service
+type Config struct {
+	ListenAddr      string        `yaml:"listen_addr"`      // address the server listens on
+	HostAddress     string        `yaml:"host_addr"`        // address the clients reach the server at, used in the location of new games
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // time allowed to the active requests to complete on shutdown
+	LogLevel        string        `yaml:"log_level"`        // one of debug, info, warn and error
+	LogFormat       string        `yaml:"log_format"`       // encoding of the log lines, console or json
+	DB              DB            `yaml:"db"`
+	Game            Game          `yaml:"game"`
+	Webhooks        Webhooks      `yaml:"webhooks"`
+}
+
+// DB is the configuration of the database
+type DB struct {
+	SQLConn         string        `yaml:"sql_conn"`          // postgres connection string, sqlite://<path> or memory://
+	QueryTimeout    time.Duration `yaml:"query_timeout"`     // time allowed to every repository call, no limit if zero
+	ConnectRetries  int           `yaml:"connect_retries"`   // attempts to reach the database on startup
+	RetryInterval   time.Duration `yaml:"retry_interval"`    // wait between the attempts
+	MaxOpenConns    int           `yaml:"max_open_conns"`    // no limit if zero, SQLite always uses a single connection
+	MaxIdleConns    int           `yaml:"max_idle_conns"`    // connections kept open while idle
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime"` // connections are reopened after, never if zero
+}
+
+// Game is the configuration of the games
+type Game struct {
+	UndoLimit         int    `yaml:"undo_limit"`         // take-backs allowed per game
+	DefaultStrategy   string `yaml:"default_strategy"`   // strategy of the games created without one
+	DefaultDifficulty string `yaml:"default_difficulty"` // difficulty of the games created without one
+}
+
+// Webhooks is the configuration of the deliveries of the events to the webhooks
+type Webhooks struct {
+	Timeout     time.Duration `yaml:"timeout"`      // time allowed to a webhook to answer a delivery
+	MaxAttempts int           `yaml:"max_attempts"` // attempts of a delivery before it fails
+	Backoff     time.Duration `yaml:"backoff"`      // wait before the first retry, doubled on every retry
+}
+
+// knownStrategy and knownDifficulty report if the games can be played with a strategy or a difficulty.
+// Any strategy and difficulty is accepted while they are nil
+var knownStrategy, knownDifficulty func(name string) bool
+
+// SetGameChoices sets the lookups of the strategies and difficulties the games can be played with,
+// so that Validate reports an unknown default strategy or difficulty along with the other problems.
+// It is meant to be called by the package playing the games while it is initialised
+func SetGameChoices(strategy, difficulty func(name string) bool) {
+	knownStrategy, knownDifficulty = strategy, difficulty
+}
+
+// Default returns the configuration used for the settings which are not set
+func Default() *Config {
+	return &Config{
+		ListenAddr:      ":8080",
+		ShutdownTimeout: 10 * time.Second,
+		LogLevel:        "debug",
+		LogFormat:       "console",
+		DB: DB{
+			QueryTimeout:   5 * time.Second,
+			ConnectRetries: 12,
+			RetryInterval:  5 * time.Second,
+			MaxIdleConns:   2,
+		},
+		Game: Game{
+			UndoLimit:         3,
+			DefaultStrategy:   "random",
+			DefaultDifficulty: "perfect",
+		},
+		Webhooks: Webhooks{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 8,
+			Backoff:     10 * time.Second,
+		},
+	}
+}
+
+// setting is a single configuration value which can be set by flag and by environment variable
+type setting struct {
+	flag string
+	env  string
+}
+
+// register binds the settings of the configuration to flags of fs
+func (c *Config) register(fs *flag.FlagSet) []setting {
+	fs.StringVar(&c.ListenAddr, "listen-addr", c.ListenAddr, "address the server listens on")
+	fs.StringVar(&c.HostAddress, "host-addr", c.HostAddress, "address the clients reach the server at")
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout, "time allowed to the active requests to complete on shutdown")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "log level, one of debug, info, warn and error")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "encoding of the log lines, console or json")
+	fs.StringVar(&c.DB.SQLConn, "sql-conn", c.DB.SQLConn, "postgres connection string, sqlite://<path> or memory://")
+	fs.DurationVar(&c.DB.QueryTimeout, "query-timeout", c.DB.QueryTimeout, "time allowed to every database call, 0 for no limit")
+	fs.IntVar(&c.DB.ConnectRetries, "db-connect-retries", c.DB.ConnectRetries, "attempts to reach the database on startup")
+	fs.DurationVar(&c.DB.RetryInterval, "db-retry-interval", c.DB.RetryInterval, "wait between the attempts to reach the database")
+	fs.IntVar(&c.DB.MaxOpenConns, "db-max-open-conns", c.DB.MaxOpenConns, "maximum open database connections, 0 for no limit")
+	fs.IntVar(&c.DB.MaxIdleConns, "db-max-idle-conns", c.DB.MaxIdleConns, "database connections kept open while idle")
+	fs.DurationVar(&c.DB.ConnMaxLifetime, "db-conn-max-lifetime", c.DB.ConnMaxLifetime, "time after which database connections are reopened, 0 for never")
+	fs.IntVar(&c.Game.UndoLimit, "undo-limit", c.Game.UndoLimit, "take-backs allowed per game")
+	fs.StringVar(&c.Game.DefaultStrategy, "default-strategy", c.Game.DefaultStrategy, "strategy of the games created without one")
+	fs.StringVar(&c.Game.DefaultDifficulty, "default-difficulty", c.Game.DefaultDifficulty, "difficulty of the games created without one")
+	fs.DurationVar(&c.Webhooks.Timeout, "webhook-timeout", c.Webhooks.Timeout, "time allowed to a webhook to answer a delivery")
+	fs.IntVar(&c.Webhooks.MaxAttempts, "webhook-max-attempts", c.Webhooks.MaxAttempts, "attempts of a webhook delivery before it fails")
+	fs.DurationVar(&c.Webhooks.Backoff, "webhook-backoff", c.Webhooks.Backoff, "wait before the first retry of a webhook delivery, doubled on every retry")
+	return []setting{
+		{flag: "listen-addr", env: "LISTEN_ADDR"},
+		{flag: "host-addr", env: "HOST_ADDR"},
+		{flag: "shutdown-timeout", env: "SHUTDOWN_TIMEOUT"},
+		{flag: "log-level", env: "LOG_LEVEL"},
+		{flag: "log-format", env: "LOG_FORMAT"},
+		{flag: "sql-conn", env: "SQL_CONN"},
+		{flag: "query-timeout", env: "QUERY_TIMEOUT"},
+		{flag: "db-connect-retries", env: "DB_CONNECT_RETRIES"},
+		{flag: "db-retry-interval", env: "DB_RETRY_INTERVAL"},
+		{flag: "db-max-open-conns", env: "DB_MAX_OPEN_CONNS"},
+		{flag: "db-max-idle-conns", env: "DB_MAX_IDLE_CONNS"},
+		{flag: "db-conn-max-lifetime", env: "DB_CONN_MAX_LIFETIME"},
+		{flag: "undo-limit", env: "UNDO_LIMIT"},
+		{flag: "default-strategy", env: "DEFAULT_STRATEGY"},
+		{flag: "default-difficulty", env: "DEFAULT_DIFFICULTY"},
+		{flag: "webhook-timeout", env: "WEBHOOK_TIMEOUT"},
+		{flag: "webhook-max-attempts", env: "WEBHOOK_MAX_ATTEMPTS"},
+		{flag: "webhook-backoff", env: "WEBHOOK_BACKOFF"},
+	}
+}
+
+// Load reads the configuration. Every setting is taken from the first of
+// the command-line flag, the environment variable, the config file given by -config or CONFIG_FILE and the default.
+// PORT is still accepted for the listen address when LISTEN_ADDR is not set.
+// The arguments left after the flags are returned along with the configuration
+func Load(name string, args []string) (*Config, []string, error) {
+	c := Default()
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	settings := c.register(fs)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "optional YAML config file")
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	// the flags are applied again after the file and the environment so that they take precedence
+	flags := make(map[string]string)
+	fs.Visit(func(f *flag.Flag) {
+		flags[f.Name] = f.Value.String()
+	})
+
+	if len(*configFile) != 0 {
+		content, err := ioutil.ReadFile(*configFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading config file: %v", err)
+		}
+		err = yaml.UnmarshalStrict(content, c)
+		if err != nil {
+			return nil, nil, fmt.Errorf("parsing config file %s: %v", *configFile, err)
+		}
+	}
+	if port := os.Getenv("PORT"); len(port) != 0 && len(os.Getenv("LISTEN_ADDR")) == 0 {
+		c.ListenAddr = ":" + port
+	}
+	for _, s := range settings {
+		if value := os.Getenv(s.env); len(value) != 0 {
+			err = fs.Set(s.flag, value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid %s in environment variable: %v", s.env, err)
+			}
+		}
+	}
+	for name, value := range flags {
+		fs.Set(name, value)
+	}
+
+	err = c.Validate()
+	if err != nil {
+		return nil, nil, err
+	}
+	return c, fs.Args(), nil
+}
+
+// Validate checks the configuration and reports all the problems found at once
+func (c *Config) Validate() error {
+	var problems []string
+	if len(c.ListenAddr) == 0 {
+		problems = append(problems, "listen address is required")
+	}
+	if _, err := url.Parse(c.HostAddress); err != nil {
+		problems = append(problems, "invalid host address: "+err.Error())
+	}
+	if c.ShutdownTimeout < 0 {
+		problems = append(problems, "shutdown timeout must not be negative")
+	}
+	var level zapcore.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err != nil {
+		problems = append(problems, "invalid log level "+c.LogLevel)
+	}
+	if c.LogFormat != "console" && c.LogFormat != "json" {
+		problems = append(problems, "invalid log format "+c.LogFormat)
+	}
+	if len(c.DB.SQLConn) == 0 {
+		problems = append(problems, "sql connection is required")
+	}
+	if c.DB.QueryTimeout < 0 {
+		problems = append(problems, "query timeout must not be negative")
+	}
+	if c.DB.ConnectRetries < 1 {
+		problems = append(problems, "db connect retries must be at least 1")
+	}
+	if c.DB.RetryInterval < 0 || c.DB.ConnMaxLifetime < 0 {
+		problems = append(problems, "db retry interval and connection lifetime must not be negative")
+	}
+	if c.DB.MaxOpenConns < 0 || c.DB.MaxIdleConns < 0 {
+		problems = append(problems, "db connection pool sizes must not be negative")
+	}
+	if c.Game.UndoLimit < 0 {
+		problems = append(problems, "undo limit must not be negative")
+	}
+	if knownStrategy != nil && !knownStrategy(c.Game.DefaultStrategy) {
+		problems = append(problems, "invalid default strategy "+c.Game.DefaultStrategy)
+	}
+	if knownDifficulty != nil && !knownDifficulty(c.Game.DefaultDifficulty) {
+		problems = append(problems, "invalid default difficulty "+c.Game.DefaultDifficulty)
+	}
+	if c.Webhooks.Timeout <= 0 || c.Webhooks.Backoff <= 0 {
+		problems = append(problems, "webhook timeout and backoff must be positive")
+	}
+	if c.Webhooks.MaxAttempts < 1 {
+		problems = append(problems, "webhook max attempts must be at least 1")
+	}
+	if len(problems) != 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
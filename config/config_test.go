@@ -0,0 +1,110 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tictactoe")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yaml")
+	content := `
+listen_addr: ":9000"
+log_level: info
+log_format: json
+db:
+  sql_conn: host=file-db
+  query_timeout: 2s
+  connect_retries: 3
+game:
+  undo_limit: 1
+  default_strategy: heuristic
+webhooks:
+  max_attempts: 3
+`
+	err = ioutil.WriteFile(configFile, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("writing config file: %v", err)
+	}
+	os.Setenv("SQL_CONN", "host=env-db")
+	os.Setenv("UNDO_LIMIT", "5")
+	defer os.Unsetenv("SQL_CONN")
+	defer os.Unsetenv("UNDO_LIMIT")
+
+	cfg, args, err := Load("tictactoe", []string{"-config", configFile, "-undo-limit", "2", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	want := Default()
+	want.ListenAddr = ":9000"
+	want.LogLevel = "info"
+	want.LogFormat = "json"
+	want.DB.SQLConn = "host=env-db"
+	want.DB.QueryTimeout = 2 * time.Second
+	want.DB.ConnectRetries = 3
+	want.Game.UndoLimit = 2
+	want.Game.DefaultStrategy = "heuristic"
+	want.Webhooks.MaxAttempts = 3
+	if *cfg != *want {
+		t.Errorf("Load() = %+v, want %+v", cfg, want)
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Errorf("Load() args = %v, want [migrate up]", args)
+	}
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	SetGameChoices(func(name string) bool { return name == "random" }, func(name string) bool { return name == "perfect" })
+	defer SetGameChoices(nil, nil)
+	tests := []struct {
+		name    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "Missing SQL Connection",
+			wantErr: "invalid configuration: sql connection is required",
+		},
+		{
+			name:    "Invalid Environment Variable",
+			env:     map[string]string{"QUERY_TIMEOUT": "soon"},
+			wantErr: "invalid QUERY_TIMEOUT in environment variable",
+		},
+		{
+			name:    "All Problems Reported",
+			args:    []string{"-sql-conn", "memory://", "-log-level", "loud", "-log-format", "xml", "-undo-limit", "-1", "-db-connect-retries", "0", "-webhook-max-attempts", "0"},
+			wantErr: "invalid configuration: invalid log level loud; invalid log format xml; db connect retries must be at least 1; undo limit must not be negative; webhook max attempts must be at least 1",
+		},
+		{
+			name:    "Unknown Game Defaults",
+			args:    []string{"-sql-conn", "memory://", "-default-strategy", "clairvoyant", "-default-difficulty", "nightmare"},
+			wantErr: "invalid configuration: invalid default strategy clairvoyant; invalid default difficulty nightmare",
+		},
+		{
+			name:    "Missing Config File",
+			args:    []string{"-config", "/does/not/exist.yaml"},
+			wantErr: "reading config file",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for name, value := range tt.env {
+				os.Setenv(name, value)
+				defer os.Unsetenv(name)
+			}
+			_, _, err := Load("tictactoe", tt.args)
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Load() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Package events fans out the changes of the games to the clients following them
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// Types of the events
+const (
+	GameCreated  = "game_created"
+	MoveMade     = "move_made"
+	MovesUndone  = "moves_undone"
+	GameFinished = "game_finished"
+	GameDeleted  = "game_deleted"
+)
+
+// subscriptionBuffer is the number of events a subscriber may fall behind before it is dropped
+const subscriptionBuffer = 64
+
+// historySize is the number of the last events kept for the subscribers resuming after an event
+const historySize = 1024
+
+// Event is a change of a game
+type Event struct {
+	ID     uint64           // assigned by the hub in the order the events are published, see NewHub
+	Type   string           // one of the event types
+	GameID string           // game which changed
+	Game   *repository.Game // state of the game after the change, nil once deleted
+}
+
+// Hub passes the events published by the handlers to the subscribers. It only reaches the subscribers of the same process.
+// Publishing never blocks: a subscriber which falls behind is dropped and has to subscribe again.
+// The last events are kept, so that a subscriber can resume after the last event it received
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event // last events, oldest first, with consecutive ids
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+// NewHub initialises a hub without subscribers.
+// Event ids start from the time the hub is created, so that they keep increasing when the service restarts
+// and an id of an earlier run is not mistaken for an event of this one
+func NewHub() *Hub {
+	return &Hub{
+		lastID:      uint64(time.Now().UnixNano()),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+}
+
+// Subscription receives the events of a game, or of all the games
+type Subscription struct {
+	hub    *Hub
+	gameID string // all the games if empty
+	events chan Event
+}
+
+// Subscribe subscribes to the events of the game, or of all the games if gameID is empty.
+// The subscription of a closed hub receives no event
+func (h *Hub) Subscribe(gameID string) *Subscription {
+	s := &Subscription{hub: h, gameID: gameID, events: make(chan Event, subscriptionBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.add(s)
+	return s
+}
+
+// SubscribeAfter subscribes like Subscribe and returns the events published after the event lastID, which the subscription
+// does not receive. complete is false if some of these events are no longer kept or lastID is not an event of the hub,
+// then only the events still kept are returned
+func (h *Hub) SubscribeAfter(gameID string, lastID uint64) (s *Subscription, missed []Event, complete bool) {
+	s = &Subscription{hub: h, gameID: gameID, events: make(chan Event, subscriptionBuffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, event := range h.history {
+		if event.ID > lastID && s.wants(event) {
+			missed = append(missed, event)
+		}
+	}
+	oldest := h.lastID + 1 - uint64(len(h.history))
+	complete = lastID+1 >= oldest && lastID <= h.lastID
+	h.add(s)
+	return s, missed, complete
+}
+
+// Events returns the channel of the events. It is closed once the subscription is closed, the subscriber fell behind
+// or the hub is closed
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.drop(s)
+}
+
+// Publish sends an event to the subscribers of the game and of all the games.
+// Publishing to a nil hub does nothing, so that handlers can run without one
+func (h *Hub) Publish(eventType string, gameID string, game *repository.Game) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	event := Event{ID: h.lastID, Type: eventType, GameID: gameID, Game: game}
+	h.history = append(h.history, event)
+	if len(h.history) > historySize {
+		h.history = h.history[len(h.history)-historySize:]
+	}
+	for s := range h.subscribers {
+		if !s.wants(event) {
+			continue
+		}
+		select {
+		case s.events <- event:
+		default:
+			h.drop(s)
+		}
+	}
+}
+
+// Close ends all the subscriptions. Nothing is published once the hub is closed
+func (h *Hub) Close() {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for s := range h.subscribers {
+		h.drop(s)
+	}
+}
+
+// wants reports if the event is one of the game of the subscription
+func (s *Subscription) wants(event Event) bool {
+	return s.gameID == "" || s.gameID == event.GameID
+}
+
+// add adds the subscription, or closes its channel if the hub is closed. Must be called with the lock held
+func (h *Hub) add(s *Subscription) {
+	if h.closed {
+		close(s.events)
+		return
+	}
+	h.subscribers[s] = struct{}{}
+}
+
+// drop removes the subscription and closes its channel. Must be called with the lock held
+func (h *Hub) drop(s *Subscription) {
+	if _, ok := h.subscribers[s]; !ok {
+		return
+	}
+	delete(h.subscribers, s)
+	close(s.events)
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+func TestHub(t *testing.T) {
+	hub := NewHub()
+	start := hub.lastID
+	game := hub.Subscribe("game-1")
+	all := hub.Subscribe("")
+	defer game.Close()
+	defer all.Close()
+
+	hub.Publish(GameCreated, "game-1", &repository.Game{ID: "game-1"})
+	hub.Publish(GameCreated, "game-2", &repository.Game{ID: "game-2"})
+	hub.Publish(GameDeleted, "game-1", nil)
+
+	for _, want := range []Event{{ID: start + 1, Type: GameCreated, GameID: "game-1"}, {ID: start + 3, Type: GameDeleted, GameID: "game-1"}} {
+		if got := <-game.Events(); got.ID != want.ID || got.Type != want.Type || got.GameID != want.GameID {
+			t.Errorf("game subscription event = %+v, want %+v", got, want)
+		}
+	}
+	for _, wantID := range []uint64{start + 1, start + 2, start + 3} {
+		if got := <-all.Events(); got.ID != wantID {
+			t.Errorf("all games subscription event = %+v, want id %v", got, wantID)
+		}
+	}
+	select {
+	case event := <-game.Events():
+		t.Errorf("game subscription event = %+v, want none", event)
+	default:
+	}
+}
+
+func TestHub_SubscribeAfter(t *testing.T) {
+	hub := NewHub()
+	start := hub.lastID
+	for i := 0; i < historySize+2; i++ {
+		hub.Publish(MoveMade, "game-1", nil)
+		hub.Publish(MoveMade, "game-2", nil)
+	}
+	last := hub.lastID
+	tests := []struct {
+		name         string
+		gameID       string
+		lastID       uint64
+		wantMissed   int
+		wantComplete bool
+	}{
+		{"all games up to date", "", last, 0, true},
+		{"all games behind", "", last - 3, 3, true},
+		{"game behind", "game-1", last - 4, 2, true},
+		{"oldest kept", "", last - historySize, historySize, true},
+		{"no longer kept", "", last - historySize - 1, historySize, false},
+		{"earlier run", "", start - 5, historySize, false},
+		{"unknown", "", last + 1, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, missed, complete := hub.SubscribeAfter(tt.gameID, tt.lastID)
+			defer s.Close()
+			if len(missed) != tt.wantMissed || complete != tt.wantComplete {
+				t.Errorf("Hub.SubscribeAfter() = %v events, %v, want %v events, %v", len(missed), complete, tt.wantMissed, tt.wantComplete)
+			}
+			for _, event := range missed {
+				if event.ID <= tt.lastID || (tt.gameID != "" && event.GameID != tt.gameID) {
+					t.Errorf("Hub.SubscribeAfter() missed event = %+v, want an event of %q after %v", event, tt.gameID, tt.lastID)
+				}
+			}
+		})
+	}
+	s, _, _ := hub.SubscribeAfter("", last)
+	defer s.Close()
+	hub.Publish(GameDeleted, "game-1", nil)
+	if event := <-s.Events(); event.ID != last+1 {
+		t.Errorf("event after SubscribeAfter() = %+v, want id %v", event, last+1)
+	}
+}
+
+func TestHub_dropsSlowSubscriber(t *testing.T) {
+	hub := NewHub()
+	slow := hub.Subscribe("")
+	for i := 0; i <= subscriptionBuffer; i++ {
+		hub.Publish(MoveMade, "game-1", &repository.Game{ID: "game-1"})
+	}
+	received := 0
+	for range slow.Events() {
+		received++
+	}
+	if received != subscriptionBuffer {
+		t.Errorf("slow subscriber received %v events, want %v before being dropped", received, subscriptionBuffer)
+	}
+	// closing a dropped subscription does nothing
+	slow.Close()
+}
+
+func TestHub_Close(t *testing.T) {
+	hub := NewHub()
+	before := hub.Subscribe("game-1")
+	hub.Close()
+	if _, ok := <-before.Events(); ok {
+		t.Errorf("subscription open after the hub was closed")
+	}
+	if _, ok := <-hub.Subscribe("game-1").Events(); ok {
+		t.Errorf("subscription to a closed hub open")
+	}
+	// publishing to a nil hub does nothing
+	var none *Hub
+	none.Publish(GameCreated, "game-1", nil)
+}
@@ -0,0 +1,89 @@
+// Package logging creates the loggers of the service and carries the logger of a request in its context
+package logging
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Encodings of the log lines
+const (
+	FormatConsole = "console"
+	FormatJSON    = "json"
+)
+
+// Level is the level of all the loggers. It can be changed at any time
+var Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+
+var (
+	mu     sync.RWMutex
+	logger *zap.Logger
+)
+
+func init() {
+	var err error
+	logger, err = newLogger(FormatConsole)
+	if err != nil {
+		log.Fatalf("cannot initialize logger. Error : %v", err)
+	}
+}
+
+// Configure sets the level and the encoding, either FormatConsole or FormatJSON, of the loggers.
+// The loggers already carried by requests keep their encoding
+func Configure(level string, format string) error {
+	err := Level.UnmarshalText([]byte(level))
+	if err != nil {
+		return err
+	}
+	configured, err := newLogger(format)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	logger = configured
+	return nil
+}
+
+// newLogger builds a logger logging at Level with the encoding
+func newLogger(format string) (*zap.Logger, error) {
+	var config zap.Config
+	switch format {
+	case FormatConsole:
+		config = zap.NewDevelopmentConfig()
+	case FormatJSON:
+		config = zap.NewProductionConfig()
+		// every access log line is kept
+		config.Sampling = nil
+	default:
+		return nil, fmt.Errorf("unknown log format %s", format)
+	}
+	config.Level = Level
+	return config.Build()
+}
+
+// Logger returns the logger of the service, used for logging outside of requests
+func Logger() *zap.Logger {
+	mu.RLock()
+	defer mu.RUnlock()
+	return logger
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying the logger of a request
+func NewContext(ctx context.Context, logger *zap.Logger) context.Context {
+	return context.WithValue(ctx, contextKey{}, logger)
+}
+
+// FromContext returns the logger of the request carried by ctx, or Logger if there is none
+func FromContext(ctx context.Context) *zap.Logger {
+	if logger, ok := ctx.Value(contextKey{}).(*zap.Logger); ok {
+		return logger
+	}
+	return Logger()
+}
@@ -0,0 +1,113 @@
+// Package metrics exposes the Prometheus metrics of the service
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "tictactoe"
+
+var (
+	// Requests counts the HTTP requests by route, method and status code
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "code"})
+	// RequestDuration observes the latency of the HTTP requests by route and method
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "Latency of the HTTP requests by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+	// GamesCreated counts the games created by the mark the computer plays
+	GamesCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "games_created_total",
+		Help:      "Games created by computer mark, none for the games between humans.",
+	}, []string{"computer_mark"})
+	// GamesFinished counts the games which ended by status and strategy of the computer
+	GamesFinished = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "games_finished_total",
+		Help:      "Games which ended by outcome (X_WON, O_WON or DRAW) and computer strategy, none for the games between humans.",
+	}, []string{"status", "strategy"})
+	// QueryDuration observes the latency of the repository calls by operation
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of the database calls by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"operation"})
+	// WebhookDeliveries counts the attempts of the webhook deliveries by outcome
+	WebhookDeliveries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "webhook_delivery_attempts_total",
+		Help:      "Attempts of the webhook deliveries by outcome: delivered, retried or failed once out of attempts.",
+	}, []string{"outcome"})
+
+	runningGames = &runningGamesCollector{
+		desc: prometheus.NewDesc(namespace+"_games_running", "Games currently running.", nil, nil),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(Requests, RequestDuration, GamesCreated, GamesFinished, QueryDuration, WebhookDeliveries, runningGames)
+}
+
+// Handler serves the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveQuery records the latency of a repository call which started at start. It is meant to be deferred
+func ObserveQuery(operation string, start time.Time) {
+	QueryDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+// CountRunningGames sets the function counting the running games when the metrics are collected
+func CountRunningGames(count func() (int, error)) {
+	runningGames.mu.Lock()
+	defer runningGames.mu.Unlock()
+	runningGames.count = count
+}
+
+// runningGamesCollector reports the number of running games, counted when the metrics are collected
+type runningGamesCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.RWMutex
+	count func() (int, error)
+}
+
+func (c *runningGamesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *runningGamesCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.RLock()
+	count := c.count
+	c.mu.RUnlock()
+	// nothing to report until there is a repository to count from
+	if count == nil {
+		return
+	}
+	running, err := count()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(running))
+}
+
+// ObserveRequest records a request served by the route, a path template such as /api/v1/games/{game_id}
+func ObserveRequest(route string, method string, code int, duration time.Duration) {
+	Requests.WithLabelValues(route, method, strconv.Itoa(code)).Inc()
+	RequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+}
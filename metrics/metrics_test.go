@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestObserveRequest(t *testing.T) {
+	before := testutil.ToFloat64(Requests.WithLabelValues("/games/{game_id}", "GET", "404"))
+	ObserveRequest("/games/{game_id}", "GET", http.StatusNotFound, time.Millisecond)
+	if got := testutil.ToFloat64(Requests.WithLabelValues("/games/{game_id}", "GET", "404")) - before; got != 1 {
+		t.Errorf("ObserveRequest() requests = %v, want 1", got)
+	}
+}
+
+func TestCountRunningGames(t *testing.T) {
+	defer CountRunningGames(nil)
+	tests := []struct {
+		name    string
+		count   func() (int, error)
+		want    int
+		wantErr bool
+	}{
+		{
+			name: "No Repository",
+			want: 0,
+		},
+		{
+			name:  "Counted",
+			count: func() (int, error) { return 3, nil },
+			want:  1,
+		},
+		{
+			name:    "Count Error",
+			count:   func() (int, error) { return 0, errors.New("db is down") },
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			CountRunningGames(tt.count)
+			registry := prometheus.NewPedanticRegistry()
+			registry.MustRegister(runningGames)
+			families, err := registry.Gather()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Gather() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			got := 0
+			for _, family := range families {
+				got += len(family.GetMetric())
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("Gather() returned %v metrics, want %v", got, tt.want)
+			}
+		})
+	}
+	CountRunningGames(func() (int, error) { return 3, nil })
+	if got := testutil.ToFloat64(runningGames); got != 3 {
+		t.Errorf("running games = %v, want 3", got)
+	}
+}
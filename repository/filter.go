@@ -0,0 +1,215 @@
+package repository
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Sort orders supported while listing games
+const (
+	SortCreatedAsc  = "created_at"
+	SortCreatedDesc = "-created_at"
+	SortUpdatedAsc  = "updated_at"
+	SortUpdatedDesc = "-updated_at"
+)
+
+// ErrInvalidCursor is returned when the cursor of a GameFilter cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursorIDPattern matches the ids of the games, which postgres compares as UUIDs
+var cursorIDPattern = regexp.MustCompile("^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$")
+
+// GameFilter narrows down, orders and pages the games returned by GetGames
+type GameFilter struct {
+	Statuses       []string  // games with any of the statuses, all if empty
+	ComputerMark   string    // games where the computer plays the mark, all if empty
+	CreatedAfter   time.Time // games created at or after, ignored if zero
+	CreatedBefore  time.Time // games created before, ignored if zero
+	UpdatedAfter   time.Time // games last updated at or after, ignored if zero
+	UpdatedBefore  time.Time // games last updated before, ignored if zero
+	FinishedAfter  time.Time // games finished at or after, ignored if zero
+	FinishedBefore time.Time // games finished before, ignored if zero
+	Sort           string    // one of the Sort constants, defaults to SortCreatedDesc. Paging by updated_at can skip or repeat games updated meanwhile
+	Limit          int       // maximum number of games in the page
+	Cursor         string    // NextCursor of the previous page, first page if empty
+}
+
+// GamePage is a page of games returned by GetGames
+type GamePage struct {
+	Games      []Game `json:"games"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	Total      int    `json:"total"`
+}
+
+// cursor is the position of the last game of a page in the sort order
+type cursor struct {
+	column string // the time column the games were sorted by
+	at     time.Time
+	id     string
+}
+
+// encodeCursor returns an opaque cursor pointing after the game in the sort order of the filter
+func (f *GameFilter) encodeCursor(game *Game) string {
+	at := game.CreatedAt
+	if f.column() == "updated_at" {
+		at = game.UpdatedAt
+	}
+	value := f.column() + "|" + at.UTC().Format(time.RFC3339Nano) + "|" + game.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+// decodeCursor decodes the cursor of the filter. A cursor from a page in another sort order is invalid
+func (f *GameFilter) decodeCursor() (*cursor, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(f.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	parts := strings.SplitN(string(decoded), "|", 3)
+	if len(parts) != 3 || parts[0] != f.column() || !cursorIDPattern.MatchString(parts[2]) {
+		return nil, ErrInvalidCursor
+	}
+	at, err := time.Parse(time.RFC3339Nano, parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{column: parts[0], at: at, id: parts[2]}, nil
+}
+
+// column returns the time column the games are ordered by
+func (f *GameFilter) column() string {
+	if f.Sort == SortUpdatedAsc || f.Sort == SortUpdatedDesc {
+		return "updated_at"
+	}
+	return "created_at"
+}
+
+// descending reports if the games are ordered newest first
+func (f *GameFilter) descending() bool {
+	return f.Sort != SortCreatedAsc && f.Sort != SortUpdatedAsc
+}
+
+// where returns the WHERE clause along with its arguments for the filter.
+// The cursor is only taken into account if withCursor is set, so that the same filter can be used for counting
+func (f *GameFilter) where(withCursor bool) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	arg := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if len(f.Statuses) != 0 {
+		placeholders := make([]string, len(f.Statuses))
+		for indx, status := range f.Statuses {
+			placeholders[indx] = arg(status)
+		}
+		conditions = append(conditions, "status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+	if len(f.ComputerMark) != 0 {
+		conditions = append(conditions, "computer_mark = "+arg(f.ComputerMark))
+	}
+	timeRanges := []struct {
+		column        string
+		after, before time.Time
+	}{
+		{"created_at", f.CreatedAfter, f.CreatedBefore},
+		{"updated_at", f.UpdatedAfter, f.UpdatedBefore},
+		{"finished_at", f.FinishedAfter, f.FinishedBefore},
+	}
+	for _, timeRange := range timeRanges {
+		if !timeRange.after.IsZero() {
+			conditions = append(conditions, timeRange.column+" >= "+arg(timeRange.after))
+		}
+		if !timeRange.before.IsZero() {
+			conditions = append(conditions, timeRange.column+" < "+arg(timeRange.before))
+		}
+	}
+	if withCursor && len(f.Cursor) != 0 {
+		c, err := f.decodeCursor()
+		if err != nil {
+			return "", nil, err
+		}
+		operator := ">"
+		if f.descending() {
+			operator = "<"
+		}
+		at, id := arg(c.at), arg(c.id)
+		conditions = append(conditions, fmt.Sprintf("(%s %s %s OR (%s = %s AND id %s %s))", c.column, operator, at, c.column, at, operator, id))
+	}
+
+	if len(conditions) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args, nil
+}
+
+// orderBy returns the ORDER BY clause for the sort order of the filter
+func (f *GameFilter) orderBy() string {
+	if f.descending() {
+		return " ORDER BY " + f.column() + " DESC, id DESC"
+	}
+	return " ORDER BY " + f.column() + " ASC, id ASC"
+}
+
+// matches reports if the game passes the filter. The cursor is not taken into account
+func (f *GameFilter) matches(game *Game) bool {
+	if len(f.Statuses) != 0 {
+		found := false
+		for _, status := range f.Statuses {
+			if status == game.Status {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.ComputerMark) != 0 && f.ComputerMark != game.ComputerMark {
+		return false
+	}
+	var finishedAt time.Time
+	if game.FinishedAt != nil {
+		finishedAt = *game.FinishedAt
+	}
+	timeRanges := []struct {
+		value         time.Time
+		after, before time.Time
+	}{
+		{game.CreatedAt, f.CreatedAfter, f.CreatedBefore},
+		{game.UpdatedAt, f.UpdatedAfter, f.UpdatedBefore},
+		{finishedAt, f.FinishedAfter, f.FinishedBefore},
+	}
+	for _, timeRange := range timeRanges {
+		// like NULL in SQL, a game which has not finished never matches a finished range
+		if (!timeRange.after.IsZero() || !timeRange.before.IsZero()) && timeRange.value.IsZero() {
+			return false
+		}
+		if !timeRange.after.IsZero() && timeRange.value.Before(timeRange.after) {
+			return false
+		}
+		if !timeRange.before.IsZero() && !timeRange.value.Before(timeRange.before) {
+			return false
+		}
+	}
+	return true
+}
+
+// less reports if game a comes before game b in the sort order of the filter
+func (f *GameFilter) less(a, b *Game) bool {
+	if f.descending() {
+		a, b = b, a
+	}
+	atA, atB := a.CreatedAt, b.CreatedAt
+	if f.column() == "updated_at" {
+		atA, atB = a.UpdatedAt, b.UpdatedAt
+	}
+	if !atA.Equal(atB) {
+		return atA.Before(atB)
+	}
+	return a.ID < b.ID
+}
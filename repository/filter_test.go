@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGameFilter_decodeCursor(t *testing.T) {
+	game := &Game{
+		ID:        "00000000-0000-4000-8000-000000000001",
+		CreatedAt: time.Date(2018, 6, 12, 20, 51, 0, 123456000, time.UTC),
+		UpdatedAt: time.Date(2018, 6, 12, 20, 52, 0, 654321000, time.UTC),
+	}
+	for _, filter := range []*GameFilter{{}, {Sort: SortCreatedAsc}, {Sort: SortUpdatedDesc}} {
+		filter.Cursor = filter.encodeCursor(game)
+		got, err := filter.decodeCursor()
+		if err != nil {
+			t.Fatalf("GameFilter.decodeCursor() sorted by %v error = %v", filter.Sort, err)
+		}
+		want := game.CreatedAt
+		if filter.Sort == SortUpdatedDesc {
+			want = game.UpdatedAt
+		}
+		if got.id != game.ID || !got.at.Equal(want) {
+			t.Errorf("GameFilter.decodeCursor() sorted by %v = %+v, want %v %v", filter.Sort, got, game.ID, want)
+		}
+	}
+	// a cursor of a page sorted by created_at cannot continue a listing sorted by updated_at
+	createdCursor := (&GameFilter{}).encodeCursor(game)
+	// postgres fails comparing the id of a tampered cursor with the UUIDs of the games
+	tamperedCursor := (&GameFilter{Sort: SortUpdatedAsc}).encodeCursor(&Game{ID: "x", UpdatedAt: game.UpdatedAt})
+	for _, value := range []string{"not base64!", "bm8gc2VwYXJhdG9y", "Y3JlYXRlZF9hdHx5ZXN0ZXJkYXl8ZHVtbXlfZ2FtZV9pZA", createdCursor, tamperedCursor} {
+		filter := &GameFilter{Sort: SortUpdatedAsc, Cursor: value}
+		if _, err := filter.decodeCursor(); err != ErrInvalidCursor {
+			t.Errorf("GameFilter.decodeCursor(%v) error = %v, want %v", value, err, ErrInvalidCursor)
+		}
+	}
+}
+
+func TestGameFilter_where(t *testing.T) {
+	createdAt := time.Date(2018, 6, 12, 0, 0, 0, 0, time.UTC)
+	cursor := (&GameFilter{}).encodeCursor(&Game{ID: "00000000-0000-4000-8000-000000000001", CreatedAt: createdAt})
+	updatedCursor := (&GameFilter{Sort: SortUpdatedDesc}).encodeCursor(&Game{ID: "00000000-0000-4000-8000-000000000001", UpdatedAt: createdAt})
+	tests := []struct {
+		name       string
+		filter     GameFilter
+		withCursor bool
+		want       string
+		wantArgs   []interface{}
+	}{
+		{
+			name:   "No Filter",
+			filter: GameFilter{},
+			want:   "",
+		},
+		{
+			name: "All Filters",
+			filter: GameFilter{
+				Statuses:      []string{"X_WON", "DRAW"},
+				ComputerMark:  "O",
+				CreatedAfter:  createdAt,
+				CreatedBefore: createdAt,
+				Sort:          SortCreatedAsc,
+				Cursor:        cursor,
+			},
+			withCursor: true,
+			want:       " WHERE status IN ($1, $2) AND computer_mark = $3 AND created_at >= $4 AND created_at < $5 AND (created_at > $6 OR (created_at = $6 AND id > $7))",
+			wantArgs:   []interface{}{"X_WON", "DRAW", "O", createdAt, createdAt, createdAt, "00000000-0000-4000-8000-000000000001"},
+		},
+		{
+			name: "Updated And Finished Ranges",
+			filter: GameFilter{
+				UpdatedBefore: createdAt,
+				FinishedAfter: createdAt,
+			},
+			want:     " WHERE updated_at < $1 AND finished_at >= $2",
+			wantArgs: []interface{}{createdAt, createdAt},
+		},
+		{
+			name: "Descending Cursor",
+			filter: GameFilter{
+				Cursor: cursor,
+			},
+			withCursor: true,
+			want:       " WHERE (created_at < $1 OR (created_at = $1 AND id < $2))",
+			wantArgs:   []interface{}{createdAt, "00000000-0000-4000-8000-000000000001"},
+		},
+		{
+			name: "Updated Cursor",
+			filter: GameFilter{
+				Sort:   SortUpdatedDesc,
+				Cursor: updatedCursor,
+			},
+			withCursor: true,
+			want:       " WHERE (updated_at < $1 OR (updated_at = $1 AND id < $2))",
+			wantArgs:   []interface{}{createdAt, "00000000-0000-4000-8000-000000000001"},
+		},
+		{
+			name: "Cursor Ignored For Counting",
+			filter: GameFilter{
+				ComputerMark: "X",
+				Cursor:       cursor,
+			},
+			want:     " WHERE computer_mark = $1",
+			wantArgs: []interface{}{"X"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, gotArgs, err := tt.filter.where(tt.withCursor)
+			if err != nil {
+				t.Fatalf("GameFilter.where() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("GameFilter.where() = %v, want %v", got, tt.want)
+			}
+			if len(gotArgs) != 0 && !reflect.DeepEqual(gotArgs, tt.wantArgs) {
+				t.Errorf("GameFilter.where() args = %v, want %v", gotArgs, tt.wantArgs)
+			}
+		})
+	}
+}
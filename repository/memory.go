@@ -0,0 +1,550 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+)
+
+// Memory is a repository which keeps the games in memory.
+// It behaves like Repository and is meant for running the service locally and in tests without a database.
+// Calls fail with the error of the context if it is already done
+type Memory struct {
+	mu         sync.RWMutex
+	games      map[string]*Game
+	moves      map[string][]Move
+	players    map[string][]Player
+	webhooks   map[string]*Webhook
+	deliveries map[string]*Delivery
+}
+
+// NewMemory initialises an empty in-memory repository
+func NewMemory() *Memory {
+	logging.Logger().Info("Using in-memory repository")
+	return &Memory{
+		games:      make(map[string]*Game),
+		moves:      make(map[string][]Move),
+		players:    make(map[string][]Player),
+		webhooks:   make(map[string]*Webhook),
+		deliveries: make(map[string]*Delivery),
+	}
+}
+
+// NewGame inserts a new game along with its opening moves and the players who already joined it.
+// On success the id, status, version and timestamps of game are set to the stored values
+func (m *Memory) NewGame(ctx context.Context, game *Game, moves []Move, players []Player) (string, error) {
+	logger := logging.FromContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	gameID, err := newUUID()
+	if err != nil {
+		logger.Error("error creating a new game id", zap.Error(err))
+		return "", err
+	}
+	now := time.Now().UTC()
+	stored := *game
+	stored.ID = gameID
+	stored.Status = "RUNNING"
+	stored.Undos = 0
+	stored.Version = 1
+	stored.CreatedAt = now
+	stored.UpdatedAt = now
+	stored.FinishedAt = nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.games[gameID] = &stored
+	*game = stored
+	m.moves[gameID] = appendMoves(nil, gameID, moves, now)
+	for i := range players {
+		m.addPlayer(gameID, &players[i], now)
+	}
+	return gameID, nil
+}
+
+// GetGames gets a page of the games matching the filter along with the total number of matching games
+func (m *Memory) GetGames(ctx context.Context, filter *GameFilter) (*GamePage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var c *cursor
+	if len(filter.Cursor) != 0 {
+		var err error
+		c, err = filter.decodeCursor()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	page := &GamePage{Games: []Game{}}
+	var games []Game
+	for _, game := range m.games {
+		if filter.matches(game) {
+			games = append(games, *game)
+		}
+	}
+	page.Total = len(games)
+	sort.Slice(games, func(i, j int) bool {
+		return filter.less(&games[i], &games[j])
+	})
+	for _, game := range games {
+		// skip the games up to and including the last game of the previous page
+		if c != nil && !filter.less(&Game{ID: c.id, CreatedAt: c.at, UpdatedAt: c.at}, &game) {
+			continue
+		}
+		if len(page.Games) == filter.Limit {
+			page.NextCursor = filter.encodeCursor(&page.Games[filter.Limit-1])
+			break
+		}
+		page.Games = append(page.Games, game)
+	}
+	return page, nil
+}
+
+// CountGames counts the games matching the filter. The cursor, sort order and limit of the filter are ignored
+func (m *Memory) CountGames(ctx context.Context, filter *GameFilter) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	total := 0
+	for _, game := range m.games {
+		if filter.matches(game) {
+			total++
+		}
+	}
+	return total, nil
+}
+
+// GetGame gets a single game
+func (m *Memory) GetGame(ctx context.Context, id string) (*Game, error) {
+	logger := logging.FromContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	game, ok := m.games[id]
+	// game not found
+	if !ok {
+		logger.Info("game not found", zap.String("id", id))
+		return nil, nil
+	}
+	found := *game
+	return &found, nil
+}
+
+// PlayMove makes a move in the game while holding the lock, so that concurrent moves are serialized.
+// play is called with a copy of the stored game, changes its board and status and returns the moves to record.
+// An error returned by play leaves the game unchanged and is returned as is.
+// Returns nil if the game is not found, else the game with its new version and timestamps
+func (m *Memory) PlayMove(ctx context.Context, id string, play func(game *Game) ([]Move, error)) (*Game, error) {
+	logger := logging.FromContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.games[id]
+	if !ok {
+		logger.Info("game not found", zap.String("id", id))
+		return nil, nil
+	}
+	game := *stored
+	moves, err := play(&game)
+	if err != nil {
+		return nil, err
+	}
+	save(stored, &game)
+	m.moves[id] = appendMoves(m.moves[id], id, moves, stored.UpdatedAt)
+	return &game, nil
+}
+
+// UndoMoves takes back all the moves of the game from the given ply onwards and updates the game.
+// The update only succeeds if game.Version is still the stored version, else ErrVersionMismatch is returned.
+// On success game.Version and the timestamps are set to the stored values
+func (m *Memory) UndoMoves(ctx context.Context, game *Game, fromPly int) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, err := m.updateVersioned(ctx, game)
+	// game not found, nothing to take back
+	if err != nil || stored == nil {
+		return 0, err
+	}
+	stored.Undos = game.Undos
+	var kept []Move
+	for _, move := range m.moves[game.ID] {
+		if move.Ply < fromPly {
+			kept = append(kept, move)
+		}
+	}
+	m.moves[game.ID] = kept
+	return 1, nil
+}
+
+// JoinGame adds a player to the game while holding the lock, so that players joining the same game at once are serialized.
+// join is called with a copy of the stored game and the players who already joined it and returns the player to add.
+// An error returned by join is returned as is.
+// Returns nil if the game is not found, else the player who joined
+func (m *Memory) JoinGame(ctx context.Context, id string, join func(game *Game, players []Player) (*Player, error)) (*Player, error) {
+	logger := logging.FromContext(ctx)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.games[id]
+	if !ok {
+		logger.Info("game not found", zap.String("id", id))
+		return nil, nil
+	}
+	game := *stored
+	players := make([]Player, len(m.players[id]))
+	copy(players, m.players[id])
+	player, err := join(&game, players)
+	if err != nil {
+		return nil, err
+	}
+	m.addPlayer(id, player, time.Now().UTC())
+	return player, nil
+}
+
+// GetPlayer gets the player of the game holding the token with the given hash. Returns nil if there is none
+func (m *Memory) GetPlayer(ctx context.Context, gameID string, tokenHash string) (*Player, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, player := range m.players[gameID] {
+		if player.TokenHash == tokenHash {
+			return &player, nil
+		}
+	}
+	logging.FromContext(ctx).Info("player not found", zap.String("id", gameID))
+	return nil, nil
+}
+
+// GetMoves gets the moves of a game ordered by ply
+func (m *Memory) GetMoves(ctx context.Context, gameID string) ([]Move, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	moves := make([]Move, len(m.moves[gameID]))
+	copy(moves, m.moves[gameID])
+	return moves, nil
+}
+
+// DeleteGame deletes the game along with its moves and players
+func (m *Memory) DeleteGame(ctx context.Context, id string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.games[id]; !ok {
+		return 0, nil
+	}
+	delete(m.games, id)
+	delete(m.moves, id)
+	delete(m.players, id)
+	return 1, nil
+}
+
+// NewWebhook inserts a new webhook. On success the id and creation time of webhook are set to the stored values
+func (m *Memory) NewWebhook(ctx context.Context, webhook *Webhook) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	webhookID, err := newUUID()
+	if err != nil {
+		logging.FromContext(ctx).Error("error creating a new webhook id", zap.Error(err))
+		return "", err
+	}
+	webhook.ID = webhookID
+	webhook.CreatedAt = time.Now().UTC()
+	stored := *webhook
+	stored.Events = append([]string(nil), webhook.Events...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.webhooks[webhookID] = &stored
+	return webhookID, nil
+}
+
+// GetWebhooks gets all the webhooks in the order they were created
+func (m *Memory) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webhooks := []Webhook{}
+	for _, webhook := range m.webhooks {
+		webhooks = append(webhooks, *webhook)
+	}
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks, nil
+}
+
+// GetWebhook gets a single webhook. Returns nil if it is not found
+func (m *Memory) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	webhook, ok := m.webhooks[id]
+	if !ok {
+		logging.FromContext(ctx).Info("webhook not found", zap.String("id", id))
+		return nil, nil
+	}
+	found := *webhook
+	return &found, nil
+}
+
+// DeleteWebhook deletes the webhook along with its deliveries
+func (m *Memory) DeleteWebhook(ctx context.Context, id string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.webhooks[id]; !ok {
+		return 0, nil
+	}
+	delete(m.webhooks, id)
+	for deliveryID, delivery := range m.deliveries {
+		if delivery.WebhookID == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+	return 1, nil
+}
+
+// NewDeliveries inserts pending deliveries, due at once.
+// On success the id, status, next attempt and creation time of every delivery are set to the stored values
+func (m *Memory) NewDeliveries(ctx context.Context, deliveries []Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	for i := range deliveries {
+		deliveryID, err := newUUID()
+		if err != nil {
+			logging.FromContext(ctx).Error("error creating a new delivery id", zap.Error(err))
+			return err
+		}
+		deliveries[i].ID = deliveryID
+		deliveries[i].Status = DeliveryPending
+		deliveries[i].NextAttemptAt = &now
+		deliveries[i].CreatedAt = now
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, delivery := range deliveries {
+		stored := delivery
+		m.deliveries[delivery.ID] = &stored
+	}
+	return nil
+}
+
+// GetDeliveries gets the last deliveries of the webhook, newest first, only the ones with the status if it is not empty
+func (m *Memory) GetDeliveries(ctx context.Context, webhookID string, status string, limit int) ([]Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	deliveries := []Delivery{}
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID == webhookID && (len(status) == 0 || delivery.Status == status) {
+			deliveries = append(deliveries, *delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID > deliveries[j].ID
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+// GetDelivery gets a single delivery of the webhook. Returns nil if it is not found
+func (m *Memory) GetDelivery(ctx context.Context, webhookID string, id string) (*Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	delivery, ok := m.deliveries[id]
+	if !ok || delivery.WebhookID != webhookID {
+		logging.FromContext(ctx).Info("delivery not found", zap.String("id", id))
+		return nil, nil
+	}
+	found := *delivery
+	return &found, nil
+}
+
+// ReplayDeliveries makes the failed deliveries of the webhook, or only the one with the id if it is not empty,
+// pending again and due at once, with all their attempts ahead of them. Returns the number of deliveries replayed
+func (m *Memory) ReplayDeliveries(ctx context.Context, webhookID string, id string) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now().UTC()
+	var replayed int64
+	for _, delivery := range m.deliveries {
+		if delivery.WebhookID != webhookID || delivery.Status != DeliveryFailed || (len(id) != 0 && delivery.ID != id) {
+			continue
+		}
+		delivery.Status = DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = &now
+		replayed++
+	}
+	return replayed, nil
+}
+
+// ClaimDeliveries gets up to limit pending deliveries which are due, oldest due first, and postpones their next attempt
+// to until while holding the lock. A delivery which is not updated before until is claimed again
+func (m *Memory) ClaimDeliveries(ctx context.Context, limit int, until time.Time) ([]Delivery, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var due []*Delivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			due = append(due, delivery)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(*due[j].NextAttemptAt)
+	})
+	deliveries := []Delivery{}
+	for _, delivery := range due {
+		if len(deliveries) == limit {
+			break
+		}
+		next := until
+		delivery.NextAttemptAt = &next
+		deliveries = append(deliveries, *delivery)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the outcome of an attempt of the delivery: its status, attempts, response code, error and times
+func (m *Memory) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.deliveries[delivery.ID]
+	// the delivery went along with its webhook
+	if !ok {
+		return nil
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.ResponseCode = delivery.ResponseCode
+	stored.LastError = delivery.LastError
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastAttemptAt = delivery.LastAttemptAt
+	return nil
+}
+
+// Close does nothing, the games are kept in memory
+func (m *Memory) Close() error {
+	return nil
+}
+
+// updateVersioned updates the board and status of the stored game if the version of game matches.
+// Returns nil if the game is not found. Must be called with the lock held
+func (m *Memory) updateVersioned(ctx context.Context, game *Game) (*Game, error) {
+	stored, ok := m.games[game.ID]
+	if !ok {
+		return nil, nil
+	}
+	if stored.Version != game.Version {
+		logging.FromContext(ctx).Info("game version mismatch", zap.String("id", game.ID), zap.Int("version", game.Version))
+		return nil, ErrVersionMismatch
+	}
+	save(stored, game)
+	return stored, nil
+}
+
+// save stores the board and status of game, bumping the version and the timestamps of both
+func save(stored *Game, game *Game) {
+	now := time.Now().UTC()
+	stored.Board = game.Board
+	stored.Status = game.Status
+	stored.Version++
+	stored.UpdatedAt = now
+	stored.FinishedAt = nil
+	if stored.Status != "RUNNING" {
+		stored.FinishedAt = &now
+	}
+	game.Version = stored.Version
+	game.UpdatedAt = stored.UpdatedAt
+	game.FinishedAt = stored.FinishedAt
+}
+
+// addPlayer stores a player of a game, setting its game and the time it joined. Must be called with the lock held
+func (m *Memory) addPlayer(gameID string, player *Player, joinedAt time.Time) {
+	player.GameID = gameID
+	player.JoinedAt = joinedAt
+	m.players[gameID] = append(m.players[gameID], *player)
+}
+
+// appendMoves appends the moves of a game stamped with the given time
+func appendMoves(stored []Move, gameID string, moves []Move, createdAt time.Time) []Move {
+	for _, move := range moves {
+		move.GameID = gameID
+		move.CreatedAt = createdAt
+		stored = append(stored, move)
+	}
+	return stored
+}
+
+// newUUID generates a random (version 4) UUID
+func newUUID() (string, error) {
+	uuid := make([]byte, 16)
+	_, err := rand.Read(uuid)
+	if err != nil {
+		return "", err
+	}
+	uuid[6] = uuid[6]&0x0f | 0x40 // version 4
+	uuid[8] = uuid[8]&0x3f | 0x80 // variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"database/sql"
+	"embed"
+	"io/fs"
+	"path"
+
+	"github.com/golang-migrate/migrate"
+	"github.com/golang-migrate/migrate/database"
+	"github.com/golang-migrate/migrate/database/postgres"
+	"github.com/golang-migrate/migrate/database/sqlite3"
+	"github.com/golang-migrate/migrate/source"
+	bindata "github.com/golang-migrate/migrate/source/go-bindata"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+)
+
+// migrationFiles are the migrations of both databases, compiled into the binary
+//
+//go:embed migrations/*.sql migrations/sqlite/*.sql
+var migrationFiles embed.FS
+
+// Migrator applies and reverts the migrations of a database
+type Migrator struct {
+	m          *migrate.Migrate
+	migrations []string // file names of the up migrations in order
+}
+
+// MigrationStatus is the state of a single migration in the database
+type MigrationStatus struct {
+	Version uint
+	Name    string
+	Applied bool
+	Dirty   bool // the migration failed part way and the database has to be fixed by hand
+}
+
+// NewMigrator connects to the database of the configuration, see New, without applying any migration
+func NewMigrator(cfg *config.DB) (*Migrator, error) {
+	db, sqlite, err := openDatabase(cfg)
+	if err != nil {
+		logging.Logger().Error("error connecting to db", zap.Error(err))
+		return nil, err
+	}
+	m, err := newMigrate(db, sqlite)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	names, err := fs.Glob(migrationFiles, path.Join(migrationsDir(sqlite), "*.up.sql"))
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{m: m, migrations: names}, nil
+}
+
+// Up applies all the migrations which are not yet applied
+func (m *Migrator) Up() error {
+	return ignoreNoChange(m.m.Up())
+}
+
+// Down reverts the last steps applied migrations
+func (m *Migrator) Down(steps int) error {
+	return ignoreNoChange(m.m.Steps(-steps))
+}
+
+// Goto applies or reverts migrations until version is the last applied migration
+func (m *Migrator) Goto(version uint) error {
+	return ignoreNoChange(m.m.Migrate(version))
+}
+
+// Status lists all the migrations known to the binary along with whether they are applied
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	current, dirty, err := m.m.Version()
+	if err != nil && err != migrate.ErrNilVersion {
+		return nil, err
+	}
+	// nothing is applied to a new database
+	migrated := err == nil
+	var statuses []MigrationStatus
+	for _, name := range m.migrations {
+		parsed, err := source.DefaultParse(path.Base(name))
+		if err != nil {
+			continue
+		}
+		statuses = append(statuses, MigrationStatus{
+			Version: parsed.Version,
+			Name:    parsed.Identifier,
+			Applied: migrated && parsed.Version <= current,
+			Dirty:   dirty && parsed.Version == current,
+		})
+	}
+	return statuses, nil
+}
+
+// Close closes the connection to the database
+func (m *Migrator) Close() error {
+	sourceErr, databaseErr := m.m.Close()
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return databaseErr
+}
+
+// migrationsDir is the folder of migrationFiles holding the migrations of the database
+func migrationsDir(sqlite bool) string {
+	if sqlite {
+		return "migrations/sqlite"
+	}
+	return "migrations"
+}
+
+// newMigrate prepares the embedded migrations to be run on the database
+func newMigrate(db *sql.DB, sqlite bool) (*migrate.Migrate, error) {
+	var driver database.Driver
+	var err error
+	databaseName := "postgres"
+	if sqlite {
+		databaseName = "sqlite3"
+		driver, err = sqlite3.WithInstance(db, &sqlite3.Config{})
+	} else {
+		driver, err = postgres.WithInstance(db, &postgres.Config{})
+	}
+	if err != nil {
+		logging.Logger().Error("failed creating driver for migration", zap.Error(err), zap.String("database", databaseName))
+		return nil, err
+	}
+
+	dir := migrationsDir(sqlite)
+	entries, err := migrationFiles.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		// the sqlite folder is skipped by the source as its name is not a migration
+		names = append(names, entry.Name())
+	}
+	sourceDriver, err := bindata.WithInstance(bindata.Resource(names, func(name string) ([]byte, error) {
+		return migrationFiles.ReadFile(path.Join(dir, name))
+	}))
+	if err != nil {
+		logging.Logger().Error("failed reading migration files", zap.Error(err))
+		return nil, err
+	}
+	return migrate.NewWithInstance("go-bindata", sourceDriver, databaseName, driver)
+}
+
+// ignoreNoChange treats a migration which has nothing to do as successful
+func ignoreNoChange(err error) error {
+	if err == migrate.ErrNoChange {
+		return nil
+	}
+	return err
+}
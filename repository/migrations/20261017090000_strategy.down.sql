@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games DROP COLUMN strategy;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN strategy VARCHAR(16) NOT NULL DEFAULT 'random';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games DROP COLUMN difficulty;
+
+COMMIT;
\ No newline at end of file
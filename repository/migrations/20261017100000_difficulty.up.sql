@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN difficulty VARCHAR(16) NOT NULL DEFAULT 'perfect';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games DROP COLUMN seed;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN seed BIGINT NOT NULL DEFAULT 0;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE moves;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE moves (
+    game_id UUID NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    ply SMALLINT NOT NULL,
+    mark CHAR(1) NOT NULL,
+    position SMALLINT NOT NULL,
+    player VARCHAR(8) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (game_id, ply)
+);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games DROP COLUMN undos;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN undos SMALLINT NOT NULL DEFAULT 0;
+
+COMMIT;
\ No newline at end of file
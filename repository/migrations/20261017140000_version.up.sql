@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+
+COMMIT;
\ No newline at end of file
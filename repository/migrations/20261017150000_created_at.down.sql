@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX games_created_at_idx;
+
+ALTER TABLE games DROP COLUMN created_at;
+
+COMMIT;
\ No newline at end of file
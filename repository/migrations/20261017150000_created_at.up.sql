@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+
+CREATE INDEX games_created_at_idx ON games (created_at, id);
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE games DROP COLUMN finished_at;
+ALTER TABLE games DROP COLUMN updated_at;
+
+COMMIT;
\ No newline at end of file
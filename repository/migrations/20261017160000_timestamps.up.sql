@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now();
+ALTER TABLE games ADD COLUMN finished_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE games SET updated_at = created_at;
+UPDATE games SET finished_at = created_at WHERE status <> 'RUNNING';
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP TABLE players;
+
+-- the games between two humans cannot be kept without a computer mark
+DELETE FROM games WHERE mode = 'human';
+ALTER TABLE games ALTER COLUMN computer_mark SET NOT NULL;
+ALTER TABLE games DROP COLUMN mode;
+
+COMMIT;
\ No newline at end of file
@@ -0,0 +1,15 @@
+BEGIN;
+
+ALTER TABLE games ADD COLUMN mode VARCHAR(8) NOT NULL DEFAULT 'computer';
+-- there is no computer in the games between two humans
+ALTER TABLE games ALTER COLUMN computer_mark DROP NOT NULL;
+
+CREATE TABLE players (
+    game_id UUID NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    mark CHAR(1) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    joined_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+    PRIMARY KEY (game_id, mark)
+);
+
+COMMIT;
\ No newline at end of file
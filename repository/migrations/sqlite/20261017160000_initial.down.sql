@@ -0,0 +1,2 @@
+DROP TABLE moves;
+DROP TABLE games;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- SQLite schema matching the postgres migrations up to the same version.
+-- Every migration is run in a transaction by the migrate sqlite3 driver, so there is no BEGIN and COMMIT here.
+-- Timestamps are stored as UTC text of fixed width so that comparing them as text orders them in time
+
+CREATE TABLE games (
+    id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    computer_mark CHAR(1) NOT NULL,
+    board VARCHAR(9) NOT NULL,
+    status VARCHAR(7) NOT NULL,
+    strategy VARCHAR(16) NOT NULL DEFAULT 'random',
+    difficulty VARCHAR(16) NOT NULL DEFAULT 'perfect',
+    seed BIGINT NOT NULL DEFAULT 0,
+    undos SMALLINT NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX games_created_at_idx ON games (created_at, id);
+
+CREATE TABLE moves (
+    game_id TEXT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    ply SMALLINT NOT NULL,
+    mark CHAR(1) NOT NULL,
+    position SMALLINT NOT NULL,
+    player VARCHAR(8) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (game_id, ply)
+);
\ No newline at end of file
@@ -0,0 +1,47 @@
+-- SQLite cannot drop the NOT NULL of computer_mark, so games is rebuilt. Dropping games would delete the moves
+-- through their foreign key, which cannot be turned off within the transaction, so the moves are set aside meanwhile
+
+CREATE TABLE moves_backup AS SELECT * FROM moves;
+DROP TABLE moves;
+
+CREATE TABLE games_rebuilt (
+    id TEXT PRIMARY KEY DEFAULT (lower(hex(randomblob(4)) || '-' || hex(randomblob(2)) || '-4' || substr(hex(randomblob(2)), 2) || '-' || substr('89ab', 1 + (abs(random()) % 4), 1) || substr(hex(randomblob(2)), 2) || '-' || hex(randomblob(6)))),
+    mode VARCHAR(8) NOT NULL DEFAULT 'computer',
+    computer_mark CHAR(1),
+    board VARCHAR(9) NOT NULL,
+    status VARCHAR(7) NOT NULL,
+    strategy VARCHAR(16) NOT NULL DEFAULT 'random',
+    difficulty VARCHAR(16) NOT NULL DEFAULT 'perfect',
+    seed BIGINT NOT NULL DEFAULT 0,
+    undos SMALLINT NOT NULL DEFAULT 0,
+    version INTEGER NOT NULL DEFAULT 1,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    finished_at TIMESTAMP
+);
+INSERT INTO games_rebuilt (id, computer_mark, board, status, strategy, difficulty, seed, undos, version, created_at, updated_at, finished_at)
+    SELECT id, computer_mark, board, status, strategy, difficulty, seed, undos, version, created_at, updated_at, finished_at FROM games;
+DROP TABLE games;
+ALTER TABLE games_rebuilt RENAME TO games;
+
+CREATE INDEX games_created_at_idx ON games (created_at, id);
+
+CREATE TABLE moves (
+    game_id TEXT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    ply SMALLINT NOT NULL,
+    mark CHAR(1) NOT NULL,
+    position SMALLINT NOT NULL,
+    player VARCHAR(8) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (game_id, ply)
+);
+INSERT INTO moves SELECT * FROM moves_backup;
+DROP TABLE moves_backup;
+
+CREATE TABLE players (
+    game_id TEXT NOT NULL REFERENCES games (id) ON DELETE CASCADE,
+    mark CHAR(1) NOT NULL,
+    token_hash CHAR(64) NOT NULL,
+    joined_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (game_id, mark)
+);
\ No newline at end of file
@@ -1,9 +1,70 @@
 package repository
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Game represents the Game table in database
 type Game struct {
-	ID           string `json:"id,omitempty"`
-	Board        string `json:"board,omitempty"`
-	Status       string `json:"status,omitempty"`
-	ComputerMark string `json:"-"`
+	ID           string     `json:"id,omitempty"`
+	Board        string     `json:"board,omitempty"`
+	Status       string     `json:"status,omitempty"`
+	Mode         string     `json:"mode,omitempty"`
+	ComputerMark string     `json:"-"`
+	Strategy     string     `json:"strategy,omitempty"`
+	Difficulty   string     `json:"difficulty,omitempty"`
+	Seed         int64      `json:"seed,omitempty"`
+	Undos        int        `json:"undos,omitempty"`
+	Version      int        `json:"-"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	FinishedAt   *time.Time `json:"finished_at,omitempty"`
+}
+
+// Move represents the Moves table in database
+type Move struct {
+	GameID    string    `json:"-"`
+	Ply       int       `json:"ply"`
+	Mark      string    `json:"mark"`
+	Position  int       `json:"position"`
+	Player    string    `json:"player"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Player represents the Players table in database, a human who joined a game between two humans.
+// Only the hash of the token of the player is stored
+type Player struct {
+	GameID    string    `json:"-"`
+	Mark      string    `json:"mark"`
+	TokenHash string    `json:"-"`
+	JoinedAt  time.Time `json:"joined_at"`
+}
+
+// Webhook represents the Webhooks table in database, an URL notified of the events of the games it subscribed to.
+// The secret signs the deliveries, it is only sent to the client which registered the webhook
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery represents the Webhook_deliveries table in database, an event sent to a webhook
+// along with the number of attempts made and the outcome of the last one
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       uint64          `json:"event_id,string"` // a string, as ids are beyond the integers a float64 holds
+	EventType     string          `json:"event_type"`
+	GameID        string          `json:"game_id"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code,omitempty"` // status code answered to the last attempt, if any
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"` // set while the delivery is pending
+	LastAttemptAt *time.Time      `json:"last_attempt_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
 }
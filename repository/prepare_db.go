@@ -3,57 +3,64 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"strings"
 	"time"
 
-	"github.com/golang-migrate/migrate"
-	"github.com/golang-migrate/migrate/database/postgres"
 	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
 )
 
-func connectDatabase(sqlConn string) (*sql.DB, error) {
-	db, err := sql.Open("postgres", sqlConn)
+// openDatabase connects to the postgres or SQLite database of the configuration, see New.
+// It reports whether the database is SQLite
+func openDatabase(cfg *config.DB) (*sql.DB, bool, error) {
+	if strings.HasPrefix(cfg.SQLConn, sqliteScheme) {
+		db, err := connectSQLite(strings.TrimPrefix(cfg.SQLConn, sqliteScheme))
+		return db, true, err
+	}
+	db, err := connectDatabase(cfg)
+	return db, false, err
+}
+
+func connectDatabase(cfg *config.DB) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.SQLConn)
 	if err != nil {
 		return nil, err
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 	isConnected := false
-	for i := 0; i < 12; i++ {
+	for i := 0; i < cfg.ConnectRetries; i++ {
 		err := db.Ping()
 		if err == nil {
-			logger.Info("Connected to database")
+			logging.Logger().Info("Connected to database")
 			isConnected = true
 			break
 		}
-		logger.Error("failed connecting to database", zap.Error(err), zap.String("connection string", sqlConn))
-		logger.Info("Retrying in 5 seconds")
-		time.Sleep(5 * time.Second)
+		logging.Logger().Error("failed connecting to database", zap.Error(err), zap.String("connection string", cfg.SQLConn))
+		if i == cfg.ConnectRetries-1 {
+			break
+		}
+		logging.Logger().Info("Retrying", zap.Duration("in", cfg.RetryInterval))
+		time.Sleep(cfg.RetryInterval)
 	}
 	if !isConnected {
 		return nil, errors.New("unable to connect to db")
 	}
-	migrateDB(db)
 	return db, nil
 }
 
-func migrateDB(db *sql.DB) error {
-	driver, err := postgres.WithInstance(db, &postgres.Config{})
+// migrateDB applies all the migrations which are not yet applied to the database
+func migrateDB(db *sql.DB, sqlite bool) error {
+	m, err := newMigrate(db, sqlite)
 	if err != nil {
-		logger.Error("failed creating postgres driver for migration", zap.Error(err))
-
 		return err
 	}
-	m, err := migrate.NewWithDatabaseInstance(
-		"file:///migrations",
-		"postgres", driver)
+	err = ignoreNoChange(m.Up())
 	if err != nil {
-		logger.Error("failed reading migration files", zap.Error(err))
-		return err
-	}
-	err = m.Up()
-	if err != nil {
-		if err == migrate.ErrNoChange {
-			return nil
-		}
-		logger.Error("failed migration", zap.Error(err))
+		logging.Logger().Error("failed migration", zap.Error(err))
 		return err
 	}
 	return nil
@@ -1,76 +1,214 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-	// blank import for registering the migration files driver
-	_ "github.com/golang-migrate/migrate/source/file"
+	"errors"
+	"fmt"
+	"time"
+
 	"go.uber.org/zap"
 	// blank import for registering the postgres driver
 	_ "github.com/lib/pq"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
 )
 
+// ErrVersionMismatch is returned when a game is updated with a version which is no longer the stored version
+var ErrVersionMismatch = errors.New("game version mismatch")
+
+// gameColumns are the columns selected for a game, in the order expected by scanGame
+const gameColumns = "id, board, status, mode, computer_mark, strategy, difficulty, seed, undos, version, created_at, updated_at, finished_at"
+
 // Repository represents the database
 type Repository struct {
-	db *sql.DB
+	db           *sql.DB
+	sqlite       bool          // queries are written for postgres and rewritten when the database is SQLite
+	queryTimeout time.Duration // time allowed to every call of the repository, no limit if zero
 }
 
-// New initialises the Database struct and conects to the database
-func New(sqlConn string) (*Repository, error) {
-	db, err := connectDatabase(sqlConn)
+// New initialises the Database struct and conects to the database.
+// cfg.SQLConn is either a postgres connection string or the path of a SQLite database file prefixed with sqlite://.
+// Every call of the repository is cancelled after cfg.QueryTimeout, unless it is zero
+func New(cfg *config.DB) (*Repository, error) {
+	db, sqlite, err := openDatabase(cfg)
+	if err != nil {
+		logging.Logger().Error("error connecting to db", zap.Error(err))
+		return nil, err
+	}
+	err = migrateDB(db, sqlite)
 	if err != nil {
-		logger.Error("error connecting to db", zap.Error(err))
+		db.Close()
 		return nil, err
 	}
 	return &Repository{
-		db: db,
+		db:           db,
+		sqlite:       sqlite,
+		queryTimeout: cfg.QueryTimeout,
 	}, nil
 }
 
-// NewGame inserts a new game to db
-func (r *Repository) NewGame(computerMark, board string) (string, error) {
+// Close closes the connections to the database
+func (r *Repository) Close() error {
+	return r.db.Close()
+}
+
+// withTimeout derives the context of a single call of the repository from ctx
+func (r *Repository) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if r.queryTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, r.queryTimeout)
+}
+
+// contextError returns the error of ctx instead of err once ctx is done.
+// The drivers report cancelled queries with errors of their own which would hide why the query failed
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+// rebind adapts a query written for postgres to the database in use
+func (r *Repository) rebind(query string) string {
+	if r.sqlite {
+		return sqliteQuery(query)
+	}
+	return query
+}
+
+// NewGame inserts a new game along with its opening moves and the players who already joined it to db.
+// On success the id, status, version and timestamps of game are set to the stored values
+func (r *Repository) NewGame(ctx context.Context, game *Game, moves []Move, players []Player) (string, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("new_game", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return "", contextError(ctx, err)
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
 
-	query := `INSERT INTO games (computer_mark, board, status) VALUES ($1, $2, $3) RETURNING id`
-	result := r.db.QueryRow(query, computerMark, board, "RUNNING")
-	var gameID string
-	err := result.Scan(&gameID)
+	query := `INSERT INTO games (mode, computer_mark, board, status, strategy, difficulty, seed) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, version, created_at, updated_at`
+	result := tx.QueryRowContext(ctx, r.rebind(query), game.Mode, nullString(game.ComputerMark), game.Board, "RUNNING", game.Strategy, game.Difficulty, game.Seed)
+	stored := *game
+	err = result.Scan(&stored.ID, &stored.Version, &stored.CreatedAt, &stored.UpdatedAt)
 	if err != nil {
-		logger.Error("error creating a new game", zap.String("computer_mark", computerMark), zap.String("board", board))
-		return "", err
+		logger.Error("error creating a new game", zap.String("computer_mark", game.ComputerMark), zap.String("board", game.Board))
+		return "", contextError(ctx, err)
 	}
-	return gameID, nil
+	err = r.insertMoves(ctx, tx, stored.ID, moves)
+	if err != nil {
+		return "", contextError(ctx, err)
+	}
+	for i := range players {
+		err = r.insertPlayer(ctx, tx, stored.ID, &players[i])
+		if err != nil {
+			return "", contextError(ctx, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit new game", zap.Error(err))
+		return "", contextError(ctx, err)
+	}
+	stored.Status = "RUNNING"
+	stored.Undos = 0
+	stored.FinishedAt = nil
+	*game = stored
+	return game.ID, nil
 }
 
-// GetGames gets all the games
-func (r *Repository) GetGames() ([]Game, error) {
-	games := []Game{}
-	//paging ignored for the timebeing
-	query := "SELECT id, board, status, computer_mark FROM games"
-	rows, err := r.db.Query(query)
+// GetGames gets a page of the games matching the filter along with the total number of matching games
+func (r *Repository) GetGames(ctx context.Context, filter *GameFilter) (*GamePage, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_games", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	total, err := r.count(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	page := &GamePage{Games: []Game{}, Total: total}
 
+	where, args, err := filter.where(true)
 	if err != nil {
-		logger.Error("failed to get games from db", zap.Error(err))
 		return nil, err
 	}
+	if r.sqlite {
+		args = sqliteArgs(args)
+	}
+	// one extra game is fetched to find out if there is a next page
+	args = append(args, filter.Limit+1)
+	query := fmt.Sprintf("SELECT %s FROM games%s%s LIMIT $%d", gameColumns, where, filter.orderBy(), len(args))
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), args...)
+
+	if err != nil {
+		logger.Error("failed to get games from db", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
 	defer rows.Close()
 	for rows.Next() {
 		game := Game{}
-		err = rows.Scan(&game.ID, &game.Board, &game.Status, &game.ComputerMark)
+		err = scanGame(rows, &game)
 		if err != nil {
 			logger.Error("failed to scan game row", zap.Error(err))
 			continue
 		}
-		games = append(games, game)
+		page.Games = append(page.Games, game)
+	}
+	if len(page.Games) > filter.Limit {
+		page.Games = page.Games[:filter.Limit]
+		page.NextCursor = filter.encodeCursor(&page.Games[filter.Limit-1])
 	}
-	return games, nil
+	return page, nil
+}
+
+// CountGames counts the games matching the filter. The cursor, sort order and limit of the filter are ignored
+func (r *Repository) CountGames(ctx context.Context, filter *GameFilter) (int, error) {
+	defer metrics.ObserveQuery("count_games", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	return r.count(ctx, filter)
+}
+
+// count counts the games matching the filter within the context of a call of the repository
+func (r *Repository) count(ctx context.Context, filter *GameFilter) (int, error) {
+	logger := logging.FromContext(ctx)
+	where, args, err := filter.where(false)
+	if err != nil {
+		return 0, err
+	}
+	if r.sqlite {
+		args = sqliteArgs(args)
+	}
+	var total int
+	err = r.db.QueryRowContext(ctx, r.rebind("SELECT COUNT(*) FROM games"+where), args...).Scan(&total)
+	if err != nil {
+		logger.Error("failed to count games in db", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
+	return total, nil
 }
 
 // GetGame gets a single game
-func (r *Repository) GetGame(id string) (*Game, error) {
+func (r *Repository) GetGame(ctx context.Context, id string) (*Game, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_game", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 	game := Game{}
-	query := "SELECT id, board, status, computer_mark FROM games WHERE id = $1"
-	row := r.db.QueryRow(query, id)
+	query := "SELECT " + gameColumns + " FROM games WHERE id = $1"
+	row := r.db.QueryRowContext(ctx, r.rebind(query), id)
 
-	err := row.Scan(&game.ID, &game.Board, &game.Status, &game.ComputerMark)
+	err := scanGame(row, &game)
 	if err != nil {
 		// game not found
 		if err == sql.ErrNoRows {
@@ -78,40 +216,308 @@ func (r *Repository) GetGame(id string) (*Game, error) {
 			return nil, nil
 		}
 		logger.Error("failed to get game from db", zap.Error(err), zap.String("id", id))
-		return nil, err
+		return nil, contextError(ctx, err)
 	}
 	return &game, nil
 }
 
-// UpdateGame updates the game
-func (r *Repository) UpdateGame(game *Game) (int64, error) {
-	query := "UPDATE games SET board = $2, status = $3 WHERE id = $1;"
-	result, err := r.db.Exec(query, game.ID, game.Board, game.Status)
+// PlayMove makes a move in the game in a single transaction.
+// The game is read FOR UPDATE, so that concurrent moves on the same game are serialized by the database.
+// play is called with the stored game, changes its board and status and returns the moves to record.
+// An error returned by play aborts the transaction and is returned as is.
+// Returns nil if the game is not found, else the game with its new version and timestamps
+func (r *Repository) PlayMove(ctx context.Context, id string, play func(game *Game) ([]Move, error)) (*Game, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("play_move", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
-		logger.Error("failed to delete game from db", zap.Error(err))
-		return 0, err
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return nil, contextError(ctx, err)
 	}
-	rowsAffected, err := result.RowsAffected()
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	game := Game{}
+	query := "SELECT " + gameColumns + " FROM games WHERE id = $1 FOR UPDATE"
+	err = scanGame(tx.QueryRowContext(ctx, r.rebind(query), id), &game)
 	if err != nil {
-		logger.Error("failed to get rows affected after deleting game", zap.Error(err))
-		return 0, err
+		// game not found
+		if err == sql.ErrNoRows {
+			logger.Info("game not found", zap.String("id", id))
+			return nil, nil
+		}
+		logger.Error("failed to lock game in db", zap.Error(err), zap.String("id", id))
+		return nil, contextError(ctx, err)
+	}
+	moves, err := play(&game)
+	if err != nil {
+		return nil, err
 	}
+	query = `UPDATE games SET board = $2, status = $3, version = version + 1, updated_at = now(),
+		finished_at = CASE WHEN $3 = 'RUNNING' THEN NULL ELSE now() END
+		WHERE id = $1 RETURNING version, updated_at, finished_at;`
+	err = tx.QueryRowContext(ctx, r.rebind(query), game.ID, game.Board, game.Status).Scan(&game.Version, &game.UpdatedAt, &game.FinishedAt)
+	if err != nil {
+		logger.Error("failed to update game in db", zap.Error(err), zap.String("id", id))
+		return nil, contextError(ctx, err)
+	}
+	err = r.insertMoves(ctx, tx, game.ID, moves)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit move", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	return &game, nil
+}
 
+// UndoMoves takes back all the moves of the game from the given ply onwards and updates the game.
+// The update only succeeds if game.Version is still the stored version, else ErrVersionMismatch is returned.
+// On success game.Version and the timestamps are set to the stored values
+func (r *Repository) UndoMoves(ctx context.Context, game *Game, fromPly int) (int64, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("undo_moves", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	query := `UPDATE games SET board = $2, status = $3, undos = $4, version = version + 1, updated_at = now(),
+		finished_at = CASE WHEN $3 = 'RUNNING' THEN NULL ELSE now() END
+		WHERE id = $1 AND version = $5 RETURNING version, updated_at, finished_at;`
+	rowsAffected, err := r.updateVersioned(ctx, tx, game, query, game.ID, game.Board, game.Status, game.Undos, game.Version)
+	// game not found, nothing to take back
+	if err != nil || rowsAffected == 0 {
+		return 0, contextError(ctx, err)
+	}
+	_, err = tx.ExecContext(ctx, r.rebind("DELETE FROM moves WHERE game_id = $1 AND ply >= $2"), game.ID, fromPly)
+	if err != nil {
+		logger.Error("failed to delete moves from db", zap.Error(err), zap.String("id", game.ID))
+		return 0, contextError(ctx, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit game undo", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
 	return rowsAffected, nil
 }
 
 // DeleteGame deletes the game
-func (r *Repository) DeleteGame(id string) (int64, error) {
+func (r *Repository) DeleteGame(ctx context.Context, id string) (int64, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("delete_game", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 	query := "DELETE FROM games WHERE id = $1"
-	result, err := r.db.Exec(query, id)
+	result, err := r.db.ExecContext(ctx, r.rebind(query), id)
 	if err != nil {
 		logger.Error("failed to delete game from db", zap.Error(err))
-		return 0, err
+		return 0, contextError(ctx, err)
 	}
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		logger.Error("failed to get rows affected after deleting game", zap.Error(err))
-		return 0, err
+		return 0, contextError(ctx, err)
 	}
 	return rowsAffected, nil
 }
+
+// GetMoves gets the moves of a game ordered by ply
+func (r *Repository) GetMoves(ctx context.Context, gameID string) ([]Move, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_moves", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	moves := []Move{}
+	query := "SELECT game_id, ply, mark, position, player, created_at FROM moves WHERE game_id = $1 ORDER BY ply"
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), gameID)
+	if err != nil {
+		logger.Error("failed to get moves from db", zap.Error(err), zap.String("id", gameID))
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		move := Move{}
+		err = rows.Scan(&move.GameID, &move.Ply, &move.Mark, &move.Position, &move.Player, &move.CreatedAt)
+		if err != nil {
+			logger.Error("failed to scan move row", zap.Error(err))
+			return nil, contextError(ctx, err)
+		}
+		moves = append(moves, move)
+	}
+	return moves, contextError(ctx, rows.Err())
+}
+
+// JoinGame adds a player to the game in a single transaction.
+// The game is read FOR UPDATE, so that players joining the same game at once are serialized by the database.
+// join is called with the stored game and the players who already joined it and returns the player to add.
+// An error returned by join aborts the transaction and is returned as is.
+// Returns nil if the game is not found, else the player who joined
+func (r *Repository) JoinGame(ctx context.Context, id string, join func(game *Game, players []Player) (*Player, error)) (*Player, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("join_game", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	game := Game{}
+	query := "SELECT " + gameColumns + " FROM games WHERE id = $1 FOR UPDATE"
+	err = scanGame(tx.QueryRowContext(ctx, r.rebind(query), id), &game)
+	if err != nil {
+		// game not found
+		if err == sql.ErrNoRows {
+			logger.Info("game not found", zap.String("id", id))
+			return nil, nil
+		}
+		logger.Error("failed to lock game in db", zap.Error(err), zap.String("id", id))
+		return nil, contextError(ctx, err)
+	}
+	players, err := r.getPlayers(ctx, tx, id)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	player, err := join(&game, players)
+	if err != nil {
+		return nil, err
+	}
+	err = r.insertPlayer(ctx, tx, id, player)
+	if err != nil {
+		return nil, contextError(ctx, err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit player", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	return player, nil
+}
+
+// GetPlayer gets the player of the game holding the token with the given hash. Returns nil if there is none
+func (r *Repository) GetPlayer(ctx context.Context, gameID string, tokenHash string) (*Player, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_player", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	player := Player{}
+	query := "SELECT game_id, mark, token_hash, joined_at FROM players WHERE game_id = $1 AND token_hash = $2"
+	err := r.db.QueryRowContext(ctx, r.rebind(query), gameID, tokenHash).Scan(&player.GameID, &player.Mark, &player.TokenHash, &player.JoinedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Info("player not found", zap.String("id", gameID))
+			return nil, nil
+		}
+		logger.Error("failed to get player from db", zap.Error(err), zap.String("id", gameID))
+		return nil, contextError(ctx, err)
+	}
+	return &player, nil
+}
+
+// getPlayers gets the players of a game ordered by the time they joined as part of the transaction
+func (r *Repository) getPlayers(ctx context.Context, tx *sql.Tx, gameID string) ([]Player, error) {
+	logger := logging.FromContext(ctx)
+	query := "SELECT game_id, mark, token_hash, joined_at FROM players WHERE game_id = $1 ORDER BY joined_at, mark"
+	rows, err := tx.QueryContext(ctx, r.rebind(query), gameID)
+	if err != nil {
+		logger.Error("failed to get players from db", zap.Error(err), zap.String("id", gameID))
+		return nil, err
+	}
+	defer rows.Close()
+	var players []Player
+	for rows.Next() {
+		player := Player{}
+		err = rows.Scan(&player.GameID, &player.Mark, &player.TokenHash, &player.JoinedAt)
+		if err != nil {
+			logger.Error("failed to scan player row", zap.Error(err))
+			return nil, err
+		}
+		players = append(players, player)
+	}
+	return players, rows.Err()
+}
+
+// insertPlayer records a player of a game as part of the transaction, setting its game and the time it joined
+func (r *Repository) insertPlayer(ctx context.Context, tx *sql.Tx, gameID string, player *Player) error {
+	query := "INSERT INTO players (game_id, mark, token_hash) VALUES ($1, $2, $3) RETURNING joined_at"
+	err := tx.QueryRowContext(ctx, r.rebind(query), gameID, player.Mark, player.TokenHash).Scan(&player.JoinedAt)
+	if err != nil {
+		logging.FromContext(ctx).Error("failed to insert player", zap.Error(err), zap.String("id", gameID), zap.String("mark", player.Mark))
+		return err
+	}
+	player.GameID = gameID
+	return nil
+}
+
+// insertMoves records the moves of a game as part of the transaction
+func (r *Repository) insertMoves(ctx context.Context, tx *sql.Tx, gameID string, moves []Move) error {
+	logger := logging.FromContext(ctx)
+	query := r.rebind("INSERT INTO moves (game_id, ply, mark, position, player) VALUES ($1, $2, $3, $4, $5)")
+	for _, move := range moves {
+		_, err := tx.ExecContext(ctx, query, gameID, move.Ply, move.Mark, move.Position, move.Player)
+		if err != nil {
+			logger.Error("failed to insert move", zap.Error(err), zap.String("id", gameID), zap.Int("ply", move.Ply))
+			return err
+		}
+	}
+	return nil
+}
+
+// updateVersioned executes the update query of a game conditioned on its version.
+// The query must return the new version, updated_at and finished_at of the game which are set on game.
+// When no row is updated it checks whether the game was deleted or its version has changed
+func (r *Repository) updateVersioned(ctx context.Context, tx *sql.Tx, game *Game, query string, args ...interface{}) (int64, error) {
+	logger := logging.FromContext(ctx)
+	err := tx.QueryRowContext(ctx, r.rebind(query), args...).Scan(&game.Version, &game.UpdatedAt, &game.FinishedAt)
+	if err == nil {
+		return 1, nil
+	}
+	if err != sql.ErrNoRows {
+		logger.Error("failed to update game in db", zap.Error(err))
+		return 0, err
+	}
+	var exists bool
+	err = tx.QueryRowContext(ctx, r.rebind("SELECT EXISTS (SELECT 1 FROM games WHERE id = $1)"), game.ID).Scan(&exists)
+	if err != nil {
+		logger.Error("failed to check if game exists", zap.Error(err))
+		return 0, err
+	}
+	if exists {
+		logger.Info("game version mismatch", zap.String("id", game.ID), zap.Int("version", game.Version))
+		return 0, ErrVersionMismatch
+	}
+	return 0, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGame scans a row selected with gameColumns into the game
+func scanGame(row scanner, game *Game) error {
+	// there is no computer mark in the games between two humans
+	var computerMark sql.NullString
+	err := row.Scan(&game.ID, &game.Board, &game.Status, &game.Mode, &computerMark, &game.Strategy, &game.Difficulty, &game.Seed, &game.Undos, &game.Version, &game.CreatedAt, &game.UpdatedAt, &game.FinishedAt)
+	game.ComputerMark = computerMark.String
+	return err
+}
+
+// nullString stores an empty string as NULL
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
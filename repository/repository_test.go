@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// store is implemented by all the repositories
+type store interface {
+	GetGames(context.Context, *GameFilter) (*GamePage, error)
+	CountGames(context.Context, *GameFilter) (int, error)
+	GetGame(context.Context, string) (*Game, error)
+	NewGame(context.Context, *Game, []Move, []Player) (string, error)
+	PlayMove(context.Context, string, func(*Game) ([]Move, error)) (*Game, error)
+	DeleteGame(context.Context, string) (int64, error)
+	GetMoves(context.Context, string) ([]Move, error)
+	UndoMoves(context.Context, *Game, int) (int64, error)
+	JoinGame(context.Context, string, func(*Game, []Player) (*Player, error)) (*Player, error)
+	GetPlayer(context.Context, string, string) (*Player, error)
+	NewWebhook(context.Context, *Webhook) (string, error)
+	GetWebhooks(context.Context) ([]Webhook, error)
+	GetWebhook(context.Context, string) (*Webhook, error)
+	DeleteWebhook(context.Context, string) (int64, error)
+	NewDeliveries(context.Context, []Delivery) error
+	GetDeliveries(context.Context, string, string, int) ([]Delivery, error)
+	GetDelivery(context.Context, string, string) (*Delivery, error)
+	ReplayDeliveries(context.Context, string, string) (int64, error)
+	ClaimDeliveries(context.Context, int, time.Time) ([]Delivery, error)
+	UpdateDelivery(context.Context, *Delivery) error
+}
+
+func TestMemory(t *testing.T) {
+	t.Run("PlayMove", func(t *testing.T) { testPlayMove(t, NewMemory()) })
+	t.Run("GetGames", func(t *testing.T) { testGetGames(t, NewMemory()) })
+	t.Run("Cancelled", func(t *testing.T) { testCancelled(t, NewMemory()) })
+	t.Run("Players", func(t *testing.T) { testPlayers(t, NewMemory()) })
+	t.Run("Webhooks", func(t *testing.T) { testWebhooks(t, NewMemory()) })
+}
+
+// testPlayMove plays, takes back and deletes a game checking versions, moves and rows affected
+func testPlayMove(t *testing.T, m store) {
+	ctx := context.Background()
+	newGame := &Game{Board: "----X----", ComputerMark: "O"}
+	gameID, err := m.NewGame(ctx, newGame, []Move{{Ply: 1, Mark: "X", Position: 4, Player: "human"}}, nil)
+	if err != nil {
+		t.Fatalf("store.NewGame() error = %v", err)
+	}
+	if newGame.ID != gameID || newGame.Version != 1 || newGame.Status != "RUNNING" || newGame.CreatedAt.IsZero() {
+		t.Errorf("store.NewGame() game = %+v, want the stored game", newGame)
+	}
+	game, _ := m.GetGame(ctx, gameID)
+	if game == nil || game.Version != 1 || game.Status != "RUNNING" {
+		t.Fatalf("store.GetGame() = %+v, want a running game at version 1", game)
+	}
+
+	game, err = m.PlayMove(ctx, gameID, func(game *Game) ([]Move, error) {
+		game.Board = "O---X----"
+		return []Move{{Ply: 2, Mark: "O", Position: 0, Player: "computer"}}, nil
+	})
+	if err != nil || game == nil || game.Version != 2 || game.Board != "O---X----" {
+		t.Fatalf("store.PlayMove() = %+v, %v, want the game at version 2", game, err)
+	}
+	rejected := errors.New("move rejected")
+	if _, err := m.PlayMove(ctx, gameID, func(game *Game) ([]Move, error) {
+		game.Board = "OX--X----"
+		return nil, rejected
+	}); err != rejected {
+		t.Errorf("store.PlayMove() rejected error = %v, want %v", err, rejected)
+	}
+	if stored, _ := m.GetGame(ctx, gameID); stored.Board != "O---X----" || stored.Version != 2 {
+		t.Errorf("store.GetGame() after rejected move = %+v, want the game unchanged", stored)
+	}
+	if moves, _ := m.GetMoves(ctx, gameID); len(moves) != 2 || moves[1].GameID != gameID {
+		t.Errorf("store.GetMoves() = %v, want 2 moves", moves)
+	}
+
+	stale := *game
+	stale.Version = 1
+	if _, err := m.UndoMoves(ctx, &stale, 2); err != ErrVersionMismatch {
+		t.Errorf("store.UndoMoves() stale error = %v, want %v", err, ErrVersionMismatch)
+	}
+	game.Board = "----X----"
+	game.Undos = 1
+	if rowsAffected, err := m.UndoMoves(ctx, game, 2); err != nil || rowsAffected != 1 || game.Version != 3 {
+		t.Errorf("store.UndoMoves() = %v, %v with version %v, want 1, <nil> with version 3", rowsAffected, err, game.Version)
+	}
+	if moves, _ := m.GetMoves(ctx, gameID); len(moves) != 1 {
+		t.Errorf("store.GetMoves() after undo = %v, want 1 move", moves)
+	}
+
+	if rowsAffected, _ := m.DeleteGame(ctx, gameID); rowsAffected != 1 {
+		t.Errorf("store.DeleteGame() = %v, want 1", rowsAffected)
+	}
+	if game, err := m.GetGame(ctx, gameID); game != nil || err != nil {
+		t.Errorf("store.GetGame() deleted = %v, %v, want <nil>, <nil>", game, err)
+	}
+	if game, err := m.PlayMove(ctx, gameID, func(*Game) ([]Move, error) { return nil, nil }); game != nil || err != nil {
+		t.Errorf("store.PlayMove() deleted = %v, %v, want <nil>, <nil>", game, err)
+	}
+	if rowsAffected, err := m.UndoMoves(ctx, game, 1); rowsAffected != 0 || err != nil {
+		t.Errorf("store.UndoMoves() deleted = %v, %v, want 0, <nil>", rowsAffected, err)
+	}
+}
+
+// testGetGames pages through games and filters them
+func testGetGames(t *testing.T, m store) {
+	ctx := context.Background()
+	var ids []string
+	for indx := 0; indx < 5; indx++ {
+		gameID, err := m.NewGame(ctx, &Game{Board: "---------", ComputerMark: "X"}, nil, nil)
+		if err != nil {
+			t.Fatalf("store.NewGame() error = %v", err)
+		}
+		ids = append(ids, gameID)
+		// timestamps are stored with millisecond precision by SQLite
+		time.Sleep(2 * time.Millisecond)
+	}
+	m.PlayMove(ctx, ids[0], func(game *Game) ([]Move, error) {
+		game.Status = "DRAW"
+		return nil, nil
+	})
+
+	pages := func(sort string, want []string) {
+		t.Helper()
+		filter := &GameFilter{Sort: sort, Limit: 2}
+		var got []string
+		for {
+			page, err := m.GetGames(ctx, filter)
+			if err != nil {
+				t.Fatalf("store.GetGames() error = %v", err)
+			}
+			if page.Total != 5 {
+				t.Errorf("store.GetGames() total = %v, want 5", page.Total)
+			}
+			for _, game := range page.Games {
+				got = append(got, game.ID)
+			}
+			if len(page.NextCursor) == 0 {
+				break
+			}
+			filter.Cursor = page.NextCursor
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("store.GetGames() sorted by %v pages = %v, want %v", sort, got, want)
+		}
+	}
+	pages(SortCreatedAsc, ids)
+	// the finished game was updated last
+	pages(SortUpdatedDesc, []string{ids[0], ids[4], ids[3], ids[2], ids[1]})
+
+	page, _ := m.GetGames(ctx, &GameFilter{Statuses: []string{"DRAW"}, Limit: 10})
+	if page.Total != 1 || page.Games[0].ID != ids[0] || page.Games[0].FinishedAt == nil {
+		t.Errorf("store.GetGames() finished = %+v, want game %v", page, ids[0])
+	}
+	page, _ = m.GetGames(ctx, &GameFilter{FinishedBefore: time.Now().Add(time.Hour), Limit: 10})
+	if page.Total != 1 {
+		t.Errorf("store.GetGames() finished before = %v games, want 1", page.Total)
+	}
+	if running, err := m.CountGames(ctx, &GameFilter{Statuses: []string{"RUNNING"}}); err != nil || running != 4 {
+		t.Errorf("store.CountGames() = %v, %v, want 4", running, err)
+	}
+	if _, err := m.GetGames(ctx, &GameFilter{Cursor: "not base64!", Limit: 10}); err != ErrInvalidCursor {
+		t.Errorf("store.GetGames() error = %v, want %v", err, ErrInvalidCursor)
+	}
+}
+
+// testCancelled checks that calls with a cancelled context fail with the error of the context
+func testCancelled(t *testing.T, m store) {
+	gameID, err := m.NewGame(context.Background(), &Game{Board: "---------", ComputerMark: "X"}, nil, nil)
+	if err != nil {
+		t.Fatalf("store.NewGame() error = %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := m.GetGame(ctx, gameID); err != context.Canceled {
+		t.Errorf("store.GetGame() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := m.PlayMove(ctx, gameID, func(*Game) ([]Move, error) { return nil, nil }); err != context.Canceled {
+		t.Errorf("store.PlayMove() error = %v, want %v", err, context.Canceled)
+	}
+	if game, _ := m.GetGame(context.Background(), gameID); game == nil || game.Version != 1 {
+		t.Errorf("store.GetGame() = %+v, want the game unchanged", game)
+	}
+}
+
+// testPlayers creates a game between two humans, lets the second player join and looks the players up by token
+func testPlayers(t *testing.T, m store) {
+	ctx := context.Background()
+	gameID, err := m.NewGame(ctx, &Game{Board: "---------", Mode: "human"}, nil, []Player{{Mark: "X", TokenHash: "hash-x"}})
+	if err != nil {
+		t.Fatalf("store.NewGame() error = %v", err)
+	}
+	if game, _ := m.GetGame(ctx, gameID); game == nil || game.Mode != "human" || game.ComputerMark != "" {
+		t.Fatalf("store.GetGame() = %+v, want a game between humans without computer mark", game)
+	}
+
+	full := errors.New("game full")
+	join := func(game *Game, players []Player) (*Player, error) {
+		if game.ID != gameID || len(players) != 1 {
+			return nil, full
+		}
+		return &Player{Mark: "O", TokenHash: "hash-o"}, nil
+	}
+	player, err := m.JoinGame(ctx, gameID, join)
+	if err != nil || player == nil || player.GameID != gameID || player.JoinedAt.IsZero() {
+		t.Fatalf("store.JoinGame() = %+v, %v, want the player who joined", player, err)
+	}
+	if _, err := m.JoinGame(ctx, gameID, join); err != full {
+		t.Errorf("store.JoinGame() second time error = %v, want %v", err, full)
+	}
+	if player, err := m.JoinGame(ctx, "00000000-0000-4000-8000-000000000000", join); player != nil || err != nil {
+		t.Errorf("store.JoinGame() not found = %v, %v, want <nil>, <nil>", player, err)
+	}
+
+	for hash, wantMark := range map[string]string{"hash-x": "X", "hash-o": "O", "unknown": ""} {
+		player, err := m.GetPlayer(ctx, gameID, hash)
+		if err != nil {
+			t.Errorf("store.GetPlayer(%v) error = %v", hash, err)
+			continue
+		}
+		if (player == nil && wantMark != "") || (player != nil && player.Mark != wantMark) {
+			t.Errorf("store.GetPlayer(%v) = %+v, want mark %q", hash, player, wantMark)
+		}
+	}
+
+	if rowsAffected, _ := m.DeleteGame(ctx, gameID); rowsAffected != 1 {
+		t.Errorf("store.DeleteGame() = %v, want 1", rowsAffected)
+	}
+	if player, err := m.GetPlayer(ctx, gameID, "hash-x"); player != nil || err != nil {
+		t.Errorf("store.GetPlayer() deleted = %v, %v, want <nil>, <nil>", player, err)
+	}
+}
+
+// testWebhooks registers a webhook, claims its deliveries, fails and replays one and deletes the webhook with its deliveries
+func testWebhooks(t *testing.T, m store) {
+	ctx := context.Background()
+	webhook := &Webhook{URL: "http://localhost/hook", Events: []string{"game_created", "game_finished"}, Secret: "secret"}
+	webhookID, err := m.NewWebhook(ctx, webhook)
+	if err != nil || webhook.ID != webhookID || webhook.CreatedAt.IsZero() {
+		t.Fatalf("store.NewWebhook() = %+v, %v, want the stored webhook", webhook, err)
+	}
+	if webhooks, err := m.GetWebhooks(ctx); err != nil || len(webhooks) != 1 || len(webhooks[0].Events) != 2 || webhooks[0].Secret != "secret" {
+		t.Errorf("store.GetWebhooks() = %+v, %v, want the webhook", webhooks, err)
+	}
+
+	deliveries := []Delivery{
+		{WebhookID: webhookID, EventID: 1, EventType: "game_created", GameID: "00000000-0000-4000-8000-000000000001", Payload: []byte(`{"type":"game_created"}`)},
+		{WebhookID: webhookID, EventID: 2, EventType: "game_finished", GameID: "00000000-0000-4000-8000-000000000001", Payload: []byte(`{"type":"game_finished"}`)},
+	}
+	if err := m.NewDeliveries(ctx, deliveries); err != nil || deliveries[0].ID == "" || deliveries[1].Status != DeliveryPending {
+		t.Fatalf("store.NewDeliveries() = %+v, %v, want pending deliveries", deliveries, err)
+	}
+	until := time.Now().Add(time.Minute)
+	claimed, err := m.ClaimDeliveries(ctx, 1, until)
+	if err != nil || len(claimed) != 1 || claimed[0].Attempts != 0 {
+		t.Fatalf("store.ClaimDeliveries() = %+v, %v, want 1 delivery", claimed, err)
+	}
+	if more, _ := m.ClaimDeliveries(ctx, 10, until); len(more) != 1 || more[0].ID == claimed[0].ID {
+		t.Errorf("store.ClaimDeliveries() again = %+v, want the other delivery", more)
+	}
+	if more, _ := m.ClaimDeliveries(ctx, 10, until); len(more) != 0 {
+		t.Errorf("store.ClaimDeliveries() claimed = %+v, want none", more)
+	}
+
+	failed := claimed[0]
+	attemptedAt := time.Now().UTC()
+	failed.Status = DeliveryFailed
+	failed.Attempts = 3
+	failed.ResponseCode = 500
+	failed.LastError = "unexpected status 500"
+	failed.NextAttemptAt = nil
+	failed.LastAttemptAt = &attemptedAt
+	if err := m.UpdateDelivery(ctx, &failed); err != nil {
+		t.Fatalf("store.UpdateDelivery() error = %v", err)
+	}
+	if got, _ := m.GetDeliveries(ctx, webhookID, DeliveryFailed, 10); len(got) != 1 || got[0].ID != failed.ID || got[0].ResponseCode != 500 || got[0].LastAttemptAt == nil {
+		t.Errorf("store.GetDeliveries() failed = %+v, want the failed delivery", got)
+	}
+	if got, _ := m.GetDeliveries(ctx, webhookID, "", 1); len(got) != 1 {
+		t.Errorf("store.GetDeliveries() limit 1 = %+v, want 1 delivery", got)
+	}
+	if got, _ := m.GetDelivery(ctx, webhookID, failed.ID); got == nil || string(got.Payload) != string(failed.Payload) || got.NextAttemptAt != nil {
+		t.Errorf("store.GetDelivery() = %+v, want the failed delivery", got)
+	}
+
+	if replayed, err := m.ReplayDeliveries(ctx, webhookID, ""); err != nil || replayed != 1 {
+		t.Errorf("store.ReplayDeliveries() = %v, %v, want 1", replayed, err)
+	}
+	if got, _ := m.GetDelivery(ctx, webhookID, failed.ID); got == nil || got.Status != DeliveryPending || got.Attempts != 0 {
+		t.Errorf("store.GetDelivery() replayed = %+v, want a pending delivery without attempts", got)
+	}
+	if replayed, _ := m.ReplayDeliveries(ctx, webhookID, failed.ID); replayed != 0 {
+		t.Errorf("store.ReplayDeliveries() pending = %v, want 0", replayed)
+	}
+	if more, _ := m.ClaimDeliveries(ctx, 10, until); len(more) != 1 || more[0].ID != failed.ID {
+		t.Errorf("store.ClaimDeliveries() replayed = %+v, want the replayed delivery", more)
+	}
+
+	if rowsAffected, _ := m.DeleteWebhook(ctx, webhookID); rowsAffected != 1 {
+		t.Errorf("store.DeleteWebhook() = %v, want 1", rowsAffected)
+	}
+	if webhook, err := m.GetWebhook(ctx, webhookID); webhook != nil || err != nil {
+		t.Errorf("store.GetWebhook() deleted = %v, %v, want <nil>, <nil>", webhook, err)
+	}
+	if delivery, err := m.GetDelivery(ctx, webhookID, failed.ID); delivery != nil || err != nil {
+		t.Errorf("store.GetDelivery() deleted = %v, %v, want <nil>, <nil>", delivery, err)
+	}
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	// blank import for registering the sqlite driver
+	_ "github.com/mattn/go-sqlite3"
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+)
+
+// sqliteScheme prefixes the path of the database file in connection strings selecting SQLite, e.g. sqlite:///data/tictactoe.db
+const sqliteScheme = "sqlite://"
+
+const (
+	// sqliteNow is the current time in the format the timestamps are stored in by the SQLite migrations
+	sqliteNow = "strftime('%Y-%m-%d %H:%M:%f', 'now')"
+	// sqliteTimeFormat formats times passed as arguments like the stored timestamps, so that they compare as text
+	sqliteTimeFormat = "2006-01-02 15:04:05.000"
+)
+
+// connectSQLite opens the database file
+func connectSQLite(path string) (*sql.DB, error) {
+	dataSource := path + "?_foreign_keys=1"
+	if strings.Contains(path, "?") {
+		dataSource = path + "&_foreign_keys=1"
+	}
+	db, err := sql.Open("sqlite3", dataSource)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows a single writer, a single connection avoids "database is locked" errors between concurrent transactions
+	db.SetMaxOpenConns(1)
+	err = db.Ping()
+	if err != nil {
+		logging.Logger().Error("failed opening sqlite database", zap.Error(err), zap.String("path", path))
+		return nil, err
+	}
+	logging.Logger().Info("Connected to sqlite database", zap.String("path", path))
+	return db, nil
+}
+
+// sqliteQuery rewrites a postgres query for SQLite.
+// $n placeholders become ?n, which SQLite numbers the same way, and now() becomes sqliteNow.
+// FOR UPDATE is dropped as SQLite does not lock rows, transactions are serialized by the single connection instead
+func sqliteQuery(query string) string {
+	query = strings.Replace(query, "$", "?", -1)
+	query = strings.Replace(query, " FOR UPDATE", "", -1)
+	return strings.Replace(query, "now()", sqliteNow, -1)
+}
+
+// sqliteArgs formats the time arguments of a query like the stored timestamps
+func sqliteArgs(args []interface{}) []interface{} {
+	for indx, arg := range args {
+		if t, ok := arg.(time.Time); ok {
+			args[indx] = t.UTC().Format(sqliteTimeFormat)
+		}
+	}
+	return args
+}
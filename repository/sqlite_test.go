@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+)
+
+// newTestSQLite creates a repository on a fresh SQLite database file
+func newTestSQLite(t *testing.T) (*Repository, func()) {
+	dir, err := ioutil.TempDir("", "tictactoe")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	r, err := New(&config.DB{SQLConn: sqliteScheme + filepath.Join(dir, "tictactoe.db"), QueryTimeout: time.Second})
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatalf("New() error = %v", err)
+	}
+	return r, func() {
+		r.db.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+func TestSQLite(t *testing.T) {
+	t.Run("PlayMove", func(t *testing.T) {
+		r, cleanup := newTestSQLite(t)
+		defer cleanup()
+		testPlayMove(t, r)
+	})
+	t.Run("GetGames", func(t *testing.T) {
+		r, cleanup := newTestSQLite(t)
+		defer cleanup()
+		testGetGames(t, r)
+	})
+	t.Run("Cancelled", func(t *testing.T) {
+		r, cleanup := newTestSQLite(t)
+		defer cleanup()
+		testCancelled(t, r)
+	})
+	t.Run("Players", func(t *testing.T) {
+		r, cleanup := newTestSQLite(t)
+		defer cleanup()
+		testPlayers(t, r)
+	})
+	t.Run("Webhooks", func(t *testing.T) {
+		r, cleanup := newTestSQLite(t)
+		defer cleanup()
+		testWebhooks(t, r)
+	})
+}
+
+func Test_sqliteQuery(t *testing.T) {
+	query := "UPDATE games SET updated_at = now() WHERE id = $1 AND version = $12"
+	want := "UPDATE games SET updated_at = " + sqliteNow + " WHERE id = ?1 AND version = ?12"
+	if got := sqliteQuery(query); got != want {
+		t.Errorf("sqliteQuery() = %v, want %v", got, want)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tictactoe")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	migrator, err := NewMigrator(&config.DB{SQLConn: sqliteScheme + filepath.Join(dir, "tictactoe.db")})
+	if err != nil {
+		t.Fatalf("NewMigrator() error = %v", err)
+	}
+	defer migrator.Close()
+	applied := func() int {
+		statuses, err := migrator.Status()
+		if err != nil {
+			t.Fatalf("Migrator.Status() error = %v", err)
+		}
+		count := 0
+		for _, status := range statuses {
+			if status.Applied {
+				count++
+			}
+		}
+		return count
+	}
+	if got := applied(); got != 0 {
+		t.Errorf("Migrator.Status() applied = %v, want 0", got)
+	}
+	if err := migrator.Up(); err != nil || applied() != 3 {
+		t.Errorf("Migrator.Up() error = %v, applied = %v, want 3", err, applied())
+	}
+	if err := migrator.Up(); err != nil {
+		t.Errorf("Migrator.Up() again error = %v", err)
+	}
+	if err := migrator.Down(2); err != nil || applied() != 1 {
+		t.Errorf("Migrator.Down() error = %v, applied = %v, want 1", err, applied())
+	}
+	if err := migrator.Goto(20261017180000); err != nil || applied() != 3 {
+		t.Errorf("Migrator.Goto() error = %v, applied = %v, want 3", err, applied())
+	}
+	if err := migrator.Down(3); err != nil || applied() != 0 {
+		t.Errorf("Migrator.Down() error = %v, applied = %v, want 0", err, applied())
+	}
+}
@@ -0,0 +1,327 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
+)
+
+// Statuses of the deliveries
+const (
+	DeliveryPending   = "pending"   // the delivery is attempted once NextAttemptAt is reached
+	DeliveryDelivered = "delivered" // the webhook accepted the delivery
+	DeliveryFailed    = "failed"    // every attempt failed, the delivery is only attempted again once replayed
+)
+
+// webhookColumns are the columns selected for a webhook, in the order expected by scanWebhook
+const webhookColumns = "id, url, events, secret, created_at"
+
+// deliveryColumns are the columns selected for a delivery, in the order expected by scanDelivery
+const deliveryColumns = "id, webhook_id, event_id, event_type, game_id, payload, status, attempts, response_code, last_error, next_attempt_at, last_attempt_at, created_at"
+
+// NewWebhook inserts a new webhook. On success the id and creation time of webhook are set to the stored values
+func (r *Repository) NewWebhook(ctx context.Context, webhook *Webhook) (string, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("new_webhook", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	query := "INSERT INTO webhooks (url, events, secret) VALUES ($1, $2, $3) RETURNING id, created_at"
+	err := r.db.QueryRowContext(ctx, r.rebind(query), webhook.URL, strings.Join(webhook.Events, ","), webhook.Secret).Scan(&webhook.ID, &webhook.CreatedAt)
+	if err != nil {
+		logger.Error("error creating a new webhook", zap.Error(err), zap.String("url", webhook.URL))
+		return "", contextError(ctx, err)
+	}
+	return webhook.ID, nil
+}
+
+// GetWebhooks gets all the webhooks in the order they were created
+func (r *Repository) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_webhooks", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	rows, err := r.db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY created_at, id")
+	if err != nil {
+		logger.Error("failed to get webhooks from db", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook := Webhook{}
+		err = scanWebhook(rows, &webhook)
+		if err != nil {
+			logger.Error("failed to scan webhook row", zap.Error(err))
+			return nil, contextError(ctx, err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, contextError(ctx, rows.Err())
+}
+
+// GetWebhook gets a single webhook. Returns nil if it is not found
+func (r *Repository) GetWebhook(ctx context.Context, id string) (*Webhook, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_webhook", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	webhook := Webhook{}
+	query := "SELECT " + webhookColumns + " FROM webhooks WHERE id = $1"
+	err := scanWebhook(r.db.QueryRowContext(ctx, r.rebind(query), id), &webhook)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Info("webhook not found", zap.String("id", id))
+			return nil, nil
+		}
+		logger.Error("failed to get webhook from db", zap.Error(err), zap.String("id", id))
+		return nil, contextError(ctx, err)
+	}
+	return &webhook, nil
+}
+
+// DeleteWebhook deletes the webhook along with its deliveries
+func (r *Repository) DeleteWebhook(ctx context.Context, id string) (int64, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("delete_webhook", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	result, err := r.db.ExecContext(ctx, r.rebind("DELETE FROM webhooks WHERE id = $1"), id)
+	if err != nil {
+		logger.Error("failed to delete webhook from db", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to get rows affected after deleting webhook", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
+	return rowsAffected, nil
+}
+
+// NewDeliveries inserts pending deliveries, due at once, in a single transaction.
+// On success the id, status, next attempt and creation time of every delivery are set to the stored values
+func (r *Repository) NewDeliveries(ctx context.Context, deliveries []Delivery) error {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("new_deliveries", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return contextError(ctx, err)
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	query := r.rebind(`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, game_id, payload, next_attempt_at)
+		VALUES ($1, $2, $3, $4, $5, now()) RETURNING id, status, next_attempt_at, created_at`)
+	for i := range deliveries {
+		d := &deliveries[i]
+		err = tx.QueryRowContext(ctx, query, d.WebhookID, int64(d.EventID), d.EventType, d.GameID, string(d.Payload)).Scan(&d.ID, &d.Status, &d.NextAttemptAt, &d.CreatedAt)
+		if err != nil {
+			logger.Error("failed to insert delivery", zap.Error(err), zap.String("webhook_id", d.WebhookID))
+			return contextError(ctx, err)
+		}
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit deliveries", zap.Error(err))
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// GetDeliveries gets the last deliveries of the webhook, newest first, only the ones with the status if it is not empty
+func (r *Repository) GetDeliveries(ctx context.Context, webhookID string, status string, limit int) ([]Delivery, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_deliveries", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1"
+	args := []interface{}{webhookID}
+	if len(status) != 0 {
+		query += " AND status = $2"
+		args = append(args, status)
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY created_at DESC, id DESC LIMIT $%d", len(args))
+	rows, err := r.db.QueryContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		logger.Error("failed to get deliveries from db", zap.Error(err), zap.String("id", webhookID))
+		return nil, contextError(ctx, err)
+	}
+	defer rows.Close()
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery := Delivery{}
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			logger.Error("failed to scan delivery row", zap.Error(err))
+			return nil, contextError(ctx, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, contextError(ctx, rows.Err())
+}
+
+// GetDelivery gets a single delivery of the webhook. Returns nil if it is not found
+func (r *Repository) GetDelivery(ctx context.Context, webhookID string, id string) (*Delivery, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("get_delivery", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	delivery := Delivery{}
+	query := "SELECT " + deliveryColumns + " FROM webhook_deliveries WHERE webhook_id = $1 AND id = $2"
+	err := scanDelivery(r.db.QueryRowContext(ctx, r.rebind(query), webhookID, id), &delivery)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			logger.Info("delivery not found", zap.String("id", id))
+			return nil, nil
+		}
+		logger.Error("failed to get delivery from db", zap.Error(err), zap.String("id", id))
+		return nil, contextError(ctx, err)
+	}
+	return &delivery, nil
+}
+
+// ReplayDeliveries makes the failed deliveries of the webhook, or only the one with the id if it is not empty,
+// pending again and due at once, with all their attempts ahead of them. Returns the number of deliveries replayed
+func (r *Repository) ReplayDeliveries(ctx context.Context, webhookID string, id string) (int64, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("replay_deliveries", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	query := `UPDATE webhook_deliveries SET status = 'pending', attempts = 0, next_attempt_at = now()
+		WHERE webhook_id = $1 AND status = 'failed'`
+	args := []interface{}{webhookID}
+	if len(id) != 0 {
+		query += " AND id = $2"
+		args = append(args, id)
+	}
+	result, err := r.db.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		logger.Error("failed to replay deliveries", zap.Error(err), zap.String("id", webhookID))
+		return 0, contextError(ctx, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		logger.Error("failed to get rows affected after replaying deliveries", zap.Error(err))
+		return 0, contextError(ctx, err)
+	}
+	return rowsAffected, nil
+}
+
+// ClaimDeliveries gets up to limit pending deliveries which are due, oldest due first, and postpones their next attempt
+// to until in a single transaction. The deliveries are read FOR UPDATE, so that the instances of the service sharing
+// the database never claim the same delivery. A delivery which is not updated before until is claimed again
+func (r *Repository) ClaimDeliveries(ctx context.Context, limit int, until time.Time) ([]Delivery, error) {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("claim_deliveries", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		logger.Error("failed to begin transaction", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	// rollback is a no-op once the transaction is committed
+	defer tx.Rollback()
+
+	query := "SELECT " + deliveryColumns + ` FROM webhook_deliveries WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at LIMIT $1 FOR UPDATE`
+	rows, err := tx.QueryContext(ctx, r.rebind(query), limit)
+	if err != nil {
+		logger.Error("failed to get due deliveries from db", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery := Delivery{}
+		err = scanDelivery(rows, &delivery)
+		if err != nil {
+			rows.Close()
+			logger.Error("failed to scan delivery row", zap.Error(err))
+			return nil, contextError(ctx, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, contextError(ctx, err)
+	}
+	query = r.rebind("UPDATE webhook_deliveries SET next_attempt_at = $2 WHERE id = $1")
+	for i := range deliveries {
+		args := []interface{}{deliveries[i].ID, until}
+		if r.sqlite {
+			args = sqliteArgs(args)
+		}
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			logger.Error("failed to claim delivery", zap.Error(err), zap.String("id", deliveries[i].ID))
+			return nil, contextError(ctx, err)
+		}
+		next := until
+		deliveries[i].NextAttemptAt = &next
+	}
+	err = tx.Commit()
+	if err != nil {
+		logger.Error("failed to commit claimed deliveries", zap.Error(err))
+		return nil, contextError(ctx, err)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery stores the outcome of an attempt of the delivery: its status, attempts, response code, error and times
+func (r *Repository) UpdateDelivery(ctx context.Context, delivery *Delivery) error {
+	logger := logging.FromContext(ctx)
+	defer metrics.ObserveQuery("update_delivery", time.Now())
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = $3, response_code = $4, last_error = $5,
+		next_attempt_at = $6, last_attempt_at = $7 WHERE id = $1`
+	args := []interface{}{delivery.ID, delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.LastError,
+		timeArg(delivery.NextAttemptAt), timeArg(delivery.LastAttemptAt)}
+	if r.sqlite {
+		args = sqliteArgs(args)
+	}
+	_, err := r.db.ExecContext(ctx, r.rebind(query), args...)
+	if err != nil {
+		logger.Error("failed to update delivery in db", zap.Error(err), zap.String("id", delivery.ID))
+		return contextError(ctx, err)
+	}
+	return nil
+}
+
+// scanWebhook scans a row selected with webhookColumns into the webhook
+func scanWebhook(row scanner, webhook *Webhook) error {
+	var events string
+	err := row.Scan(&webhook.ID, &webhook.URL, &events, &webhook.Secret, &webhook.CreatedAt)
+	webhook.Events = strings.Split(events, ",")
+	return err
+}
+
+// scanDelivery scans a row selected with deliveryColumns into the delivery
+func scanDelivery(row scanner, delivery *Delivery) error {
+	var payload string
+	err := row.Scan(&delivery.ID, &delivery.WebhookID, &delivery.EventID, &delivery.EventType, &delivery.GameID, &payload,
+		&delivery.Status, &delivery.Attempts, &delivery.ResponseCode, &delivery.LastError, &delivery.NextAttemptAt,
+		&delivery.LastAttemptAt, &delivery.CreatedAt)
+	delivery.Payload = json.RawMessage(payload)
+	return err
+}
+
+// timeArg passes an optional time as a query argument, NULL if it is nil
+func timeArg(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
@@ -0,0 +1,374 @@
+// Package webhooks delivers the events of the games to the webhooks registered by the clients
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/logging"
+	"github.com/sunilkumarmohanty/tictactoe/metrics"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// EventTypes are the types of the events a webhook can subscribe to
+var EventTypes = []string{events.GameCreated, events.MoveMade, events.GameFinished, events.MovesUndone, events.GameDeleted}
+
+// Headers of the deliveries
+const (
+	HeaderEvent     = "X-Tictactoe-Event"     // type of the event
+	HeaderDelivery  = "X-Tictactoe-Delivery"  // id of the delivery, the same on every attempt
+	HeaderSignature = "X-Tictactoe-Signature" // signature of the body, see Sign
+)
+
+const (
+	claimBatch      = 16               // deliveries claimed and attempted at once
+	pollInterval    = 5 * time.Second  // longest wait before looking for due deliveries again
+	maxBackoff      = time.Hour        // longest wait between two attempts of a delivery
+	claimMargin     = 30 * time.Second // time allowed to record an attempt on top of the timeout of the webhook
+	resubscribeWait = time.Second      // wait before subscribing again to the hub after the subscription ended
+	maxDrainedBody  = 64 << 10         // bytes of the response read so that the connection can be reused
+)
+
+// Outcomes of the attempts in the metrics
+const (
+	outcomeDelivered = "delivered"
+	outcomeRetried   = "retried"
+	outcomeFailed    = "failed"
+)
+
+// Store keeps the webhooks and their deliveries. It is implemented by the repositories
+type Store interface {
+	GetWebhooks(context.Context) ([]repository.Webhook, error)
+	GetWebhook(context.Context, string) (*repository.Webhook, error)
+	NewDeliveries(context.Context, []repository.Delivery) error
+	ClaimDeliveries(context.Context, int, time.Time) ([]repository.Delivery, error)
+	UpdateDelivery(context.Context, *repository.Delivery) error
+}
+
+// payload is the body of a delivery. It carries the same game as the messages of the WebSockets and the event streams
+type payload struct {
+	EventID uint64           `json:"event_id,string"` // a string, as ids are beyond the integers a float64 holds
+	Type    string           `json:"type"`
+	GameID  string           `json:"game_id"`
+	Version int              `json:"version,omitempty"` // version of the game, as in its ETag
+	Game    *repository.Game `json:"game,omitempty"`
+}
+
+// Dispatcher records a delivery of every event published to the hub for each webhook subscribed to its type,
+// and attempts the deliveries until the webhook accepts them or they run out of attempts.
+// The deliveries are stored before they are attempted, so they are retried by any instance of the service sharing the database
+type Dispatcher struct {
+	store        Store
+	hub          *events.Hub
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration // wait before the first retry, doubled on every retry
+	claim        time.Duration // time a claimed delivery is kept from the other instances while it is attempted
+	pollInterval time.Duration
+	wake         chan struct{}
+	ctx          context.Context // cancelled once the dispatcher is closed, abandoning the attempts in flight
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+}
+
+// NewDispatcher starts delivering the events published to the hub to the webhooks of the store
+func NewDispatcher(store Store, hub *events.Hub, cfg *config.Webhooks) *Dispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &Dispatcher{
+		store: store,
+		hub:   hub,
+		client: &http.Client{
+			Timeout: cfg.Timeout,
+			// a redirect is answered like any other status which is not 2xx
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		maxAttempts:  cfg.MaxAttempts,
+		backoff:      cfg.Backoff,
+		claim:        cfg.Timeout + claimMargin,
+		pollInterval: pollInterval,
+		wake:         make(chan struct{}, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+	}
+	// retries are not delayed by the polling when the backoff is shorter
+	if d.backoff < d.pollInterval {
+		d.pollInterval = d.backoff
+	}
+	d.wg.Add(2)
+	// subscribed before returning, so that no event published once the dispatcher is started is missed
+	go d.recordEvents(hub.Subscribe(""))
+	go d.deliverDue()
+	return d
+}
+
+// Close stops the dispatcher and waits for it. The attempts in flight are abandoned,
+// their deliveries are attempted again once their claim runs out. Closing a nil dispatcher does nothing
+func (d *Dispatcher) Close() {
+	if d == nil {
+		return
+	}
+	d.cancel()
+	d.wg.Wait()
+}
+
+// Wake makes the dispatcher look for due deliveries at once, e.g. after deliveries were replayed.
+// Waking a nil dispatcher does nothing
+func (d *Dispatcher) Wake() {
+	if d == nil {
+		return
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default:
+		// already woken
+	}
+}
+
+// Sign returns the signature of the body of a delivery sent in HeaderSignature: sha256= followed by
+// the hex encoded HMAC-SHA256 of the body keyed with the secret of the webhook
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// recordEvents records the deliveries of the events published to the hub until the dispatcher is closed.
+// When the dispatcher falls behind and its subscription ends, it subscribes again after the last event it recorded,
+// the events it missed are then taken from the history of the hub
+func (d *Dispatcher) recordEvents(subscription *events.Subscription) {
+	defer d.wg.Done()
+	logger := logging.Logger()
+	var lastID uint64
+	for {
+		select {
+		case <-d.ctx.Done():
+			subscription.Close()
+			return
+		case event, ok := <-subscription.Events():
+			if ok {
+				lastID = event.ID
+				d.record(event)
+				continue
+			}
+			select {
+			case <-d.ctx.Done():
+				return
+			case <-time.After(resubscribeWait):
+			}
+			if lastID == 0 {
+				subscription = d.hub.Subscribe("")
+				continue
+			}
+			var missed []events.Event
+			var complete bool
+			subscription, missed, complete = d.hub.SubscribeAfter("", lastID)
+			if !complete {
+				logger.Error("webhook events lost", zap.Uint64("last_event_id", lastID))
+			}
+			for _, event := range missed {
+				lastID = event.ID
+				d.record(event)
+			}
+		}
+	}
+}
+
+// record records a delivery of the event for every webhook subscribed to its type
+func (d *Dispatcher) record(event events.Event) {
+	logger := logging.Logger()
+	webhooks, err := d.store.GetWebhooks(d.ctx)
+	if err != nil {
+		logger.Error("unable to get webhooks", zap.Error(err), zap.Uint64("event_id", event.ID))
+		return
+	}
+	var body []byte
+	var deliveries []repository.Delivery
+	for _, webhook := range webhooks {
+		if !subscribed(&webhook, event.Type) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(newPayload(event))
+			if err != nil {
+				logger.Error("unable to encode webhook payload", zap.Error(err), zap.Uint64("event_id", event.ID))
+				return
+			}
+		}
+		deliveries = append(deliveries, repository.Delivery{
+			WebhookID: webhook.ID,
+			EventID:   event.ID,
+			EventType: event.Type,
+			GameID:    event.GameID,
+			Payload:   body,
+		})
+	}
+	if len(deliveries) == 0 {
+		return
+	}
+	err = d.store.NewDeliveries(d.ctx, deliveries)
+	if err != nil {
+		logger.Error("unable to record webhook deliveries", zap.Error(err), zap.Uint64("event_id", event.ID))
+		return
+	}
+	d.Wake()
+}
+
+// deliverDue attempts the due deliveries whenever woken and every poll interval until the dispatcher is closed
+func (d *Dispatcher) deliverDue() {
+	defer d.wg.Done()
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+	for {
+		d.attemptDue()
+		select {
+		case <-d.ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// attemptDue claims the due deliveries a batch at a time and attempts the deliveries of a batch at once
+func (d *Dispatcher) attemptDue() {
+	for {
+		deliveries, err := d.store.ClaimDeliveries(d.ctx, claimBatch, time.Now().Add(d.claim))
+		if err != nil {
+			if d.ctx.Err() == nil {
+				logging.Logger().Error("unable to claim webhook deliveries", zap.Error(err))
+			}
+			return
+		}
+		var wg sync.WaitGroup
+		for i := range deliveries {
+			wg.Add(1)
+			go func(delivery *repository.Delivery) {
+				defer wg.Done()
+				d.attempt(delivery)
+			}(&deliveries[i])
+		}
+		wg.Wait()
+		if len(deliveries) < claimBatch {
+			return
+		}
+	}
+}
+
+// attempt posts a claimed delivery to its webhook and records the outcome. A failed attempt is retried after the backoff,
+// doubled on every attempt, until the delivery runs out of attempts and fails
+func (d *Dispatcher) attempt(delivery *repository.Delivery) {
+	logger := logging.Logger().With(zap.String("delivery_id", delivery.ID), zap.String("webhook_id", delivery.WebhookID))
+	webhook, err := d.store.GetWebhook(d.ctx, delivery.WebhookID)
+	if err != nil {
+		// the delivery is attempted again once its claim runs out
+		logger.Error("unable to get webhook", zap.Error(err))
+		return
+	}
+	// the webhook was deleted along with its deliveries
+	if webhook == nil {
+		return
+	}
+	code, err := d.post(webhook, delivery)
+	// the dispatcher is closing, the delivery is attempted again once its claim runs out
+	if d.ctx.Err() != nil {
+		return
+	}
+	now := time.Now().UTC()
+	delivery.Attempts++
+	delivery.ResponseCode = code
+	delivery.LastAttemptAt = &now
+	delivery.NextAttemptAt = nil
+	delivery.LastError = ""
+	outcome := outcomeDelivered
+	switch {
+	case err == nil:
+		delivery.Status = repository.DeliveryDelivered
+	case delivery.Attempts >= d.maxAttempts:
+		outcome = outcomeFailed
+		delivery.Status = repository.DeliveryFailed
+		delivery.LastError = err.Error()
+	default:
+		outcome = outcomeRetried
+		next := now.Add(d.retryDelay(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+		delivery.LastError = err.Error()
+	}
+	logger.Info("webhook delivery attempted", zap.String("outcome", outcome), zap.Int("attempts", delivery.Attempts), zap.Int("code", code), zap.Error(err))
+	metrics.WebhookDeliveries.WithLabelValues(outcome).Inc()
+	err = d.store.UpdateDelivery(d.ctx, delivery)
+	if err != nil {
+		logger.Error("unable to record webhook delivery", zap.Error(err))
+	}
+}
+
+// post sends the payload of the delivery to the webhook. Returns the status code of the response, if any,
+// and an error unless the webhook accepted the delivery with a 2xx status
+func (d *Dispatcher) post(webhook *repository.Webhook, delivery *repository.Delivery) (int, error) {
+	req, err := http.NewRequest("POST", webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req = req.WithContext(d.ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "tictactoe-webhooks")
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, delivery.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryDelay returns the wait before the next attempt of a delivery which failed the given number of attempts
+func (d *Dispatcher) retryDelay(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
+
+// subscribed reports if the webhook subscribed to the events of the type
+func subscribed(webhook *repository.Webhook, eventType string) bool {
+	for _, subscribedType := range webhook.Events {
+		if subscribedType == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// newPayload returns the body of the deliveries of the event
+func newPayload(event events.Event) payload {
+	p := payload{EventID: event.ID, Type: event.Type, GameID: event.GameID, Game: event.Game}
+	if event.Game != nil {
+		p.Version = event.Game.Version
+	}
+	return p
+}
@@ -0,0 +1,163 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sunilkumarmohanty/tictactoe/config"
+	"github.com/sunilkumarmohanty/tictactoe/events"
+	"github.com/sunilkumarmohanty/tictactoe/repository"
+)
+
+// receiver is a webhook answering the deliveries with the status codes it is given, then with 200
+type receiver struct {
+	mu       sync.Mutex
+	codes    []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	if len(rc.codes) != 0 {
+		rw.WriteHeader(rc.codes[0])
+		rc.codes = rc.codes[1:]
+	}
+}
+
+// waitFor waits for the deliveries of the webhook with the status
+func waitFor(t *testing.T, store *repository.Memory, webhookID string, status string, count int) []repository.Delivery {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		deliveries, _ := store.GetDeliveries(context.Background(), webhookID, status, 10)
+		if len(deliveries) == count {
+			return deliveries
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries %v = %+v, want %v", status, deliveries, count)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemory()
+	hub := events.NewHub()
+	defer hub.Close()
+
+	flaky := &receiver{codes: []int{http.StatusInternalServerError}}
+	flakyServer := httptest.NewServer(flaky)
+	defer flakyServer.Close()
+	down := &receiver{codes: []int{http.StatusServiceUnavailable, http.StatusFound}}
+	downServer := httptest.NewServer(down)
+	defer downServer.Close()
+
+	flakyHook := &repository.Webhook{URL: flakyServer.URL, Events: []string{events.GameCreated, events.GameFinished}, Secret: "flaky-secret"}
+	store.NewWebhook(ctx, flakyHook)
+	downHook := &repository.Webhook{URL: downServer.URL, Events: []string{events.GameFinished}, Secret: "down-secret"}
+	store.NewWebhook(ctx, downHook)
+
+	dispatcher := NewDispatcher(store, hub, &config.Webhooks{Timeout: time.Second, MaxAttempts: 2, Backoff: 10 * time.Millisecond})
+	defer dispatcher.Close()
+
+	game := &repository.Game{ID: "00000000-0000-4000-8000-000000000001", Board: "XXXOO----", Status: "X_WON", Version: 3}
+	hub.Publish(events.GameCreated, game.ID, game)
+	hub.Publish(events.MoveMade, game.ID, game)
+	hub.Publish(events.GameFinished, game.ID, game)
+
+	// either delivery may be the one answered with 500 first
+	delivered := waitFor(t, store, flakyHook.ID, repository.DeliveryDelivered, 2)
+	if attempts := delivered[0].Attempts + delivered[1].Attempts; attempts != 3 {
+		t.Errorf("attempts = %v, want 3", attempts)
+	}
+	for _, delivery := range delivered {
+		if delivery.ResponseCode != http.StatusOK || delivery.NextAttemptAt != nil {
+			t.Errorf("delivery = %+v, want delivered", delivery)
+		}
+	}
+	flaky.mu.Lock()
+	if len(flaky.requests) != 3 {
+		t.Errorf("requests = %v, want 3", len(flaky.requests))
+	}
+	for indx, r := range flaky.requests {
+		body := flaky.bodies[indx]
+		if got := r.Header.Get(HeaderSignature); got != Sign("flaky-secret", body) {
+			t.Errorf("signature = %v, want %v", got, Sign("flaky-secret", body))
+		}
+		message := payload{}
+		if err := json.Unmarshal(body, &message); err != nil || message.Type != r.Header.Get(HeaderEvent) || message.Version != 3 || message.Game.Board != game.Board {
+			t.Errorf("payload = %+v, %v, want the %v event of the game", message, err, r.Header.Get(HeaderEvent))
+		}
+	}
+	flaky.mu.Unlock()
+
+	failed := waitFor(t, store, downHook.ID, repository.DeliveryFailed, 1)[0]
+	if failed.Attempts != 2 || failed.ResponseCode != http.StatusFound || failed.LastError != "unexpected status 302" {
+		t.Errorf("failed delivery = %+v, want 2 attempts ending with 302", failed)
+	}
+	if replayed, _ := store.ReplayDeliveries(ctx, downHook.ID, failed.ID); replayed != 1 {
+		t.Fatalf("replayed = %v, want 1", replayed)
+	}
+	dispatcher.Wake()
+	if replayed := waitFor(t, store, downHook.ID, repository.DeliveryDelivered, 1)[0]; replayed.ID != failed.ID || replayed.Attempts != 1 {
+		t.Errorf("replayed delivery = %+v, want %v delivered on its first attempt", replayed, failed.ID)
+	}
+}
+
+// Test_newPayload checks that the event ids, which start from the time the service started, survive JSON decoders using float64
+func Test_newPayload(t *testing.T) {
+	id := uint64(1792000000000000043)
+	want := strconv.FormatUint(id, 10)
+	body, err := json.Marshal(newPayload(events.Event{ID: id, Type: events.GameCreated, GameID: "00000000-0000-4000-8000-000000000001"}))
+	if err != nil {
+		t.Fatalf("encoding payload: %v", err)
+	}
+	decoded := map[string]interface{}{}
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded["event_id"] != want {
+		t.Errorf("payload event_id = %v, %v, want %v", decoded["event_id"], err, want)
+	}
+	message := payload{}
+	if err := json.Unmarshal(body, &message); err != nil || message.EventID != id {
+		t.Errorf("payload = %+v, %v, want event %v", message, err, id)
+	}
+
+	body, err = json.Marshal(repository.Delivery{EventID: id})
+	if err != nil {
+		t.Fatalf("encoding delivery: %v", err)
+	}
+	decoded = map[string]interface{}{}
+	if err := json.Unmarshal(body, &decoded); err != nil || decoded["event_id"] != want {
+		t.Errorf("delivery event_id = %v, %v, want %v", decoded["event_id"], err, want)
+	}
+}
+
+func TestDispatcher_retryDelay(t *testing.T) {
+	d := &Dispatcher{backoff: 10 * time.Second}
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{4, 80 * time.Second},
+		{20, maxBackoff},
+	}
+	for _, tt := range tests {
+		if got := d.retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("Dispatcher.retryDelay(%v) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}